@@ -0,0 +1,215 @@
+package sqlf
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+)
+
+/*
+Batch accumulates statements to be executed together.
+
+	var b sqlf.Batch
+	for _, u := range users {
+		b.Add(sqlf.InsertInto("users").
+			Set("id", u.ID).
+			Set("name", u.Name))
+	}
+	err := b.ExecBatch(ctx, db)
+
+Statements added to a batch are closed by ExecBatch.
+Do not reuse them afterwards.
+*/
+type Batch struct {
+	stmts []*Stmt
+}
+
+/*
+BatchExecutor is an Executor able to send several statements
+to a database in a single round trip.
+
+Implement it to make ExecBatch use a driver-specific batch API,
+like pgx.Batch.
+*/
+type BatchExecutor interface {
+	Executor
+	ExecBatch(ctx context.Context, queries []string, args [][]interface{}) error
+}
+
+// txBeginner is implemented by sql.DB and sql.Conn.
+type txBeginner interface {
+	BeginTx(ctx context.Context, opts *sql.TxOptions) (*sql.Tx, error)
+}
+
+// Add adds a statement to a batch.
+func (b *Batch) Add(q *Stmt) *Batch {
+	b.stmts = append(b.stmts, q)
+	return b
+}
+
+// Len returns the number of statements in a batch.
+func (b *Batch) Len() int {
+	return len(b.stmts)
+}
+
+/*
+ExecBatch executes all the statements of a batch, closes them
+and empties the batch.
+
+If db implements BatchExecutor, statements are passed to its
+ExecBatch method. Otherwise, statements are executed one by one.
+sql.DB and sql.Conn executors run them within a transaction.
+
+In dry run mode statements are not executed.
+*/
+func (b *Batch) ExecBatch(ctx context.Context, db Executor) (err error) {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	defer b.close()
+
+	if isDryRun() {
+		return nil
+	}
+
+	if be, ok := db.(BatchExecutor); ok {
+		queries := make([]string, len(b.stmts))
+		args := make([][]interface{}, len(b.stmts))
+		for i, q := range b.stmts {
+			if err := q.check(); err != nil {
+				return err
+			}
+			queries[i] = q.String()
+			args[i] = q.args
+		}
+		return be.ExecBatch(ctx, queries, args)
+	}
+
+	if tb, ok := db.(txBeginner); ok {
+		tx, err := tb.BeginTx(ctx, nil)
+		if err != nil {
+			return err
+		}
+		err = b.exec(ctx, tx)
+		if err != nil {
+			tx.Rollback()
+			return err
+		}
+		return tx.Commit()
+	}
+
+	return b.exec(ctx, db)
+}
+
+// exec executes batch statements one by one.
+func (b *Batch) exec(ctx context.Context, db Executor) error {
+	for _, q := range b.stmts {
+		if _, err := q.Exec(ctx, db); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// close closes batch statements and empties the batch.
+func (b *Batch) close() {
+	for i, q := range b.stmts {
+		q.Close()
+		b.stmts[i] = nil
+	}
+	b.stmts = b.stmts[:0]
+}
+
+/*
+InsertBatch inserts rows into a table using as few multi-row
+INSERT statements as possible:
+
+	n, err := sqlf.PostgreSQL.InsertBatch(ctx, db, "users",
+		[]string{"id", "name"},
+		[][]interface{}{{1, "Ann"}, {2, "Bob"}, {3, "Eve"}},
+		1000)
+
+Every statement gets at most batchSize rows. A statement never has
+more than MaxPostgreSQLArgs arguments no matter the dialect, so the batch
+size is reduced if needed. MySQL accepts as many arguments, but SQLite
+prior to 3.32 is limited to 999, so pass a smaller batchSize for it.
+Pass zero batchSize to insert as many rows per statement as the
+limit allows.
+
+InsertBatch returns an error without inserting anything if a row
+has a number of values that doesn't match the number of columns.
+
+If rows don't fit into a single statement and db is an sql.DB
+or sql.Conn, the statements are executed within a transaction.
+
+InsertBatch returns the total number of inserted rows.
+*/
+func (b *Dialect) InsertBatch(ctx context.Context, db Executor, table string, columns []string, rows [][]interface{}, batchSize int) (int64, error) {
+	if len(rows) == 0 {
+		return 0, nil
+	}
+	if len(columns) == 0 {
+		return 0, errors.New("sqlf: InsertBatch is called with no columns")
+	}
+	for i, values := range rows {
+		if len(values) != len(columns) {
+			return 0, fmt.Errorf("sqlf: InsertBatch row %d has %d value(s) for %d column(s)", i+1, len(values), len(columns))
+		}
+	}
+	if limit := MaxPostgreSQLArgs / len(columns); batchSize <= 0 || batchSize > limit {
+		batchSize = limit
+	}
+	if ctx == nil {
+		ctx = context.Background()
+	}
+
+	if tb, ok := db.(txBeginner); ok && len(rows) > batchSize {
+		tx, err := tb.BeginTx(ctx, nil)
+		if err != nil {
+			return 0, err
+		}
+		n, err := b.insertBatch(ctx, tx, table, columns, rows, batchSize)
+		if err != nil {
+			tx.Rollback()
+			return 0, err
+		}
+		return n, tx.Commit()
+	}
+
+	return b.insertBatch(ctx, db, table, columns, rows, batchSize)
+}
+
+// insertBatch executes INSERT statements of up to batchSize rows each.
+func (b *Dialect) insertBatch(ctx context.Context, db Executor, table string, columns []string, rows [][]interface{}, batchSize int) (total int64, err error) {
+	for len(rows) > 0 {
+		n := batchSize
+		if n > len(rows) {
+			n = len(rows)
+		}
+		q := b.InsertInto(table)
+		for _, values := range rows[:n] {
+			row := q.NewRow()
+			for i, col := range columns {
+				row = row.Set(col, values[i])
+			}
+		}
+		res, err := q.ExecAndClose(ctx, db)
+		if err != nil {
+			return total, err
+		}
+		affected, err := res.RowsAffected()
+		if err != nil {
+			return total, err
+		}
+		total += affected
+		rows = rows[n:]
+	}
+	return total, nil
+}
+
+// InsertBatch inserts rows into a table using multi-row INSERT statements
+// of up to batchSize rows each. See Dialect.InsertBatch for details.
+func InsertBatch(ctx context.Context, db Executor, table string, columns []string, rows [][]interface{}, batchSize int) (int64, error) {
+	return defaultDialect.InsertBatch(ctx, db, table, columns, rows, batchSize)
+}
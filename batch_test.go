@@ -0,0 +1,113 @@
+package sqlf_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/leporo/sqlf"
+	"github.com/stretchr/testify/require"
+)
+
+type batchDB struct {
+	recordingDB
+	queries []string
+	args    [][]interface{}
+}
+
+func (db *batchDB) ExecBatch(ctx context.Context, queries []string, args [][]interface{}) error {
+	db.queries = queries
+	for _, a := range args {
+		db.args = append(db.args, append([]interface{}(nil), a...))
+	}
+	return nil
+}
+
+func TestBatch(t *testing.T) {
+	forEveryDB(t, func(ctx context.Context, env *dbEnv) {
+		var b sqlf.Batch
+		b.Add(env.sqlf.InsertInto("users").Set("id", 4).Set("name", "User 4")).
+			Add(env.sqlf.Update("users").Set("name", "User 1a").Where("id = ?", 1))
+		require.Equal(t, 2, b.Len())
+		err := b.ExecBatch(ctx, env.db)
+		require.NoError(t, err)
+		require.Equal(t, 0, b.Len())
+
+		var cnt int
+		err = env.sqlf.From("users").
+			Select("COUNT(*)").To(&cnt).
+			Where("name IN (?, ?)", "User 4", "User 1a").
+			QueryRowAndClose(ctx, env.db)
+		require.NoError(t, err)
+		require.Equal(t, 2, cnt)
+
+		// A failed statement rolls the batch back
+		b.Add(env.sqlf.DeleteFrom("users").Where("id = ?", 4)).
+			Add(env.sqlf.InsertInto("no_such_table").Set("id", 1))
+		require.Error(t, b.ExecBatch(ctx, env.db))
+		err = env.sqlf.From("users").
+			Select("COUNT(*)").To(&cnt).
+			QueryRowAndClose(ctx, env.db)
+		require.NoError(t, err)
+		require.Equal(t, 4, cnt)
+	})
+}
+
+func TestBatchExecutor(t *testing.T) {
+	db := new(batchDB)
+	var b sqlf.Batch
+	b.Add(sqlf.PostgreSQL.DeleteFrom("users").Where("id = ?", 1)).
+		Add(sqlf.PostgreSQL.DeleteFrom("orders").Where("user_id = ?", 1))
+	require.NoError(t, b.ExecBatch(nil, db))
+	require.Equal(t, []string{"DELETE FROM users WHERE id = $1", "DELETE FROM orders WHERE user_id = $1"}, db.queries)
+	require.Equal(t, [][]interface{}{{1}, {1}}, db.args)
+}
+
+func TestInsertBatch(t *testing.T) {
+	forEveryDB(t, func(ctx context.Context, env *dbEnv) {
+		rows := [][]interface{}{{10, "User 10"}, {11, "User 11"}, {12, "User 12"}, {13, "User 13"}, {14, "User 14"}}
+		n, err := env.sqlf.InsertBatch(ctx, env.db, "users", []string{"id", "name"}, rows, 2)
+		require.NoError(t, err)
+		require.Equal(t, int64(5), n)
+
+		var cnt int
+		err = env.sqlf.From("users").
+			Select("COUNT(*)").To(&cnt).
+			Where("id >= ?", 10).
+			QueryRowAndClose(ctx, env.db)
+		require.NoError(t, err)
+		require.Equal(t, 5, cnt)
+
+		// A failed statement rolls all the batches back
+		rows = [][]interface{}{{20, "User 20"}, {21, "User 21"}, {10, "User 10"}}
+		_, err = env.sqlf.InsertBatch(ctx, env.db, "users", []string{"id", "name"}, rows, 2)
+		require.Error(t, err)
+		err = env.sqlf.From("users").
+			Select("COUNT(*)").To(&cnt).
+			Where("id >= ?", 20).
+			QueryRowAndClose(ctx, env.db)
+		require.NoError(t, err)
+		require.Equal(t, 0, cnt)
+
+		// Rows not matching columns are rejected before anything is inserted
+		for _, rows := range [][][]interface{}{
+			{{30, "User 30"}, {31, "User 31", "extra"}},
+			{{30, "User 30"}, {31}},
+		} {
+			_, err = env.sqlf.InsertBatch(ctx, env.db, "users", []string{"id", "name"}, rows, 1)
+			require.Error(t, err)
+		}
+		err = env.sqlf.From("users").
+			Select("COUNT(*)").To(&cnt).
+			Where("id >= ?", 30).
+			QueryRowAndClose(ctx, env.db)
+		require.NoError(t, err)
+		require.Equal(t, 0, cnt)
+
+		_, err = env.sqlf.InsertBatch(ctx, env.db, "users", nil, [][]interface{}{{}}, 0)
+		require.Error(t, err)
+
+		n, err = sqlf.InsertBatch(ctx, env.db, "users", []string{"id"}, nil, 0)
+		require.NoError(t, err)
+		require.Equal(t, int64(0), n)
+	})
+}
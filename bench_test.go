@@ -1,6 +1,7 @@
 package sqlf_test
 
 import (
+	"bytes"
 	"fmt"
 	"testing"
 
@@ -268,3 +269,54 @@ func BenchmarkIn(b *testing.B) {
 		q.Close()
 	}
 }
+
+func BenchmarkStringBatch(b *testing.B) {
+	sqlf.PostgreSQL.ClearCache()
+	var buf bytes.Buffer
+	for i := 0; i < b.N; i++ {
+		q := sqlf.PostgreSQL.Select("id").From("table").Where("id > ?", i)
+		buf.WriteString(q.String())
+		q.Close()
+		buf.Reset()
+		sqlf.PostgreSQL.ClearCache()
+	}
+}
+
+func BenchmarkWriteToBatch(b *testing.B) {
+	sqlf.PostgreSQL.ClearCache()
+	var buf bytes.Buffer
+	for i := 0; i < b.N; i++ {
+		q := sqlf.PostgreSQL.Select("id").From("table").Where("id > ?", i)
+		q.WriteTo(&buf)
+		q.Close()
+		buf.Reset()
+		sqlf.PostgreSQL.ClearCache()
+	}
+}
+
+func benchmarkInterleaved(b *testing.B, grow int) {
+	fields := make([]string, 0, 50)
+	conds := make([]string, 0, cap(fields))
+	for n := 1; n <= cap(fields); n++ {
+		fields = append(fields, fmt.Sprintf("field_%d", n))
+		conds = append(conds, fmt.Sprintf("field_%d > ?", n))
+	}
+	sqlf.NoDialect.ClearCache()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		q := sqlf.From("table").Grow(grow)
+		for n, field := range fields {
+			q.Select(field).Where(conds[n], n)
+		}
+		s = q.String()
+		q.Close()
+	}
+}
+
+func BenchmarkInterleaved(b *testing.B) {
+	benchmarkInterleaved(b, 0)
+}
+
+func BenchmarkInterleavedGrow(b *testing.B) {
+	benchmarkInterleaved(b, 100)
+}
@@ -0,0 +1,226 @@
+package sqlf
+
+import (
+	"strings"
+)
+
+/*
+Cond is an SQL fragment along with arguments matching its placeholders.
+
+Use it to pass reusable conditions around:
+
+	func activeUsers() sqlf.Cond {
+		return sqlf.Cond{Expr: "is_active = ?", Args: []interface{}{true}}
+	}
+*/
+type Cond struct {
+	Expr string
+	Args []interface{}
+}
+
+/*
+Expr creates a condition or any other reusable SQL fragment:
+
+	c := sqlf.Expr("status = ?", "new").
+		Or(sqlf.Expr("amount > ?", 100))
+
+Pass it to WhereCond, HavingCond or SetCond methods of a statement.
+*/
+func Expr(expr string, args ...interface{}) Cond {
+	return Cond{Expr: expr, Args: args}
+}
+
+// And combines conditions with AND and encloses the result in parentheses.
+func (c Cond) And(conds ...Cond) Cond {
+	return c.join(" AND ", conds)
+}
+
+// Or combines conditions with OR and encloses the result in parentheses.
+func (c Cond) Or(conds ...Cond) Cond {
+	return c.join(" OR ", conds)
+}
+
+func (c Cond) join(sep string, conds []Cond) Cond {
+	if len(conds) == 0 {
+		return c
+	}
+	all := make([]Cond, 0, len(conds)+1)
+	all = append(all, c)
+	all = append(all, conds...)
+	expr, args := joinConds(sep, all)
+	return Cond{Expr: expr, Args: args}
+}
+
+// joinConds joins conditions with a separator and encloses them in parentheses.
+func joinConds(sep string, conds []Cond) (string, []interface{}) {
+	var (
+		buf  strings.Builder
+		args []interface{}
+	)
+	buf.WriteByte('(')
+	for i, c := range conds {
+		if i > 0 {
+			buf.WriteString(sep)
+		}
+		buf.WriteString(c.Expr)
+		args = append(args, c.Args...)
+	}
+	buf.WriteByte(')')
+	return buf.String(), args
+}
+
+/*
+Coalesce builds a COALESCE function call:
+
+	q.Select(sqlf.Coalesce(sqlf.Expr("nickname"), sqlf.Expr("name"), "anonymous").Expr)
+
+produces
+
+	SELECT COALESCE(nickname, name, ?)
+
+Cond arguments are embedded as SQL expressions,
+any other values are passed as statement arguments.
+*/
+func Coalesce(args ...interface{}) Cond {
+	return call("COALESCE", args)
+}
+
+/*
+Greatest builds a GREATEST function call:
+
+	q.SetCond("balance", sqlf.Greatest(sqlf.Expr("balance - ?", amount), 0))
+
+produces
+
+	SET balance=GREATEST(balance - ?, ?)
+
+Cond arguments are embedded as SQL expressions,
+any other values are passed as statement arguments.
+*/
+func Greatest(args ...interface{}) Cond {
+	return call("GREATEST", args)
+}
+
+/*
+Least builds a LEAST function call.
+
+Cond arguments are embedded as SQL expressions,
+any other values are passed as statement arguments.
+*/
+func Least(args ...interface{}) Cond {
+	return call("LEAST", args)
+}
+
+/*
+AggFilter builds a PostgreSQL aggregate expression with a FILTER clause:
+
+	paid := sqlf.AggFilter("COUNT(*)", "status = ?", "paid")
+	q.Select(paid.Expr, paid.Args...)
+
+produces
+
+	SELECT COUNT(*) FILTER (WHERE status = ?)
+
+args are the arguments of condition.
+*/
+func AggFilter(agg, condition string, args ...interface{}) Cond {
+	return Cond{Expr: agg + " FILTER (WHERE " + condition + ")", Args: args}
+}
+
+// call builds a function call expression.
+func call(name string, args []interface{}) Cond {
+	var (
+		buf   strings.Builder
+		cargs []interface{}
+	)
+	buf.WriteString(name)
+	buf.WriteByte('(')
+	for i, arg := range args {
+		if i > 0 {
+			buf.WriteString(", ")
+		}
+		if c, ok := arg.(Cond); ok {
+			buf.WriteString(c.Expr)
+			cargs = append(cargs, c.Args...)
+		} else {
+			buf.WriteByte('?')
+			cargs = append(cargs, arg)
+		}
+	}
+	buf.WriteByte(')')
+	return Cond{Expr: buf.String(), Args: cargs}
+}
+
+/*
+CaseBuilder builds a CASE expression.
+
+Use Case function to create one:
+
+	c := sqlf.Case().
+		When("amount > ?", "?", 1000, "large").
+		When("amount > ?", "?", 100, "medium").
+		Else("?", "small").
+		As("size").
+		Cond()
+	q.Select(c.Expr, c.Args...)
+
+produces
+
+	SELECT CASE WHEN amount > ? THEN ? WHEN amount > ? THEN ? ELSE ? END AS size
+
+with arguments ordered the way they appear in the expression.
+*/
+type CaseBuilder struct {
+	whens    []Cond
+	elseExpr *Cond
+	alias    string
+}
+
+// Case starts a CASE expression.
+func Case() *CaseBuilder {
+	return &CaseBuilder{}
+}
+
+// When adds a WHEN cond THEN then branch.
+// args are arguments for placeholders of both cond and then expressions.
+func (c *CaseBuilder) When(cond, then string, args ...interface{}) *CaseBuilder {
+	c.whens = append(c.whens, Cond{Expr: "WHEN " + cond + " THEN " + then, Args: args})
+	return c
+}
+
+// Else sets an ELSE branch.
+func (c *CaseBuilder) Else(expr string, args ...interface{}) *CaseBuilder {
+	c.elseExpr = &Cond{Expr: "ELSE " + expr, Args: args}
+	return c
+}
+
+// As sets an alias for a CASE expression to be used in a SELECT clause.
+func (c *CaseBuilder) As(alias string) *CaseBuilder {
+	c.alias = alias
+	return c
+}
+
+// Cond returns a CASE expression along with its arguments.
+func (c *CaseBuilder) Cond() Cond {
+	var (
+		buf  strings.Builder
+		args []interface{}
+	)
+	buf.WriteString("CASE")
+	for _, w := range c.whens {
+		buf.WriteByte(' ')
+		buf.WriteString(w.Expr)
+		args = append(args, w.Args...)
+	}
+	if c.elseExpr != nil {
+		buf.WriteByte(' ')
+		buf.WriteString(c.elseExpr.Expr)
+		args = append(args, c.elseExpr.Args...)
+	}
+	buf.WriteString(" END")
+	if c.alias != "" {
+		buf.WriteString(" AS ")
+		buf.WriteString(c.alias)
+	}
+	return Cond{Expr: buf.String(), Args: args}
+}
@@ -0,0 +1,87 @@
+package sqlf_test
+
+import (
+	"testing"
+
+	"github.com/leporo/sqlf"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCond(t *testing.T) {
+	c := sqlf.Expr("status = ?", "new").
+		Or(sqlf.Expr("amount > ?", 100), sqlf.Expr("user_id = ?", 1)).
+		And(sqlf.Expr("deleted_at IS NULL"))
+	require.Equal(t, "((status = ? OR amount > ? OR user_id = ?) AND deleted_at IS NULL)", c.Expr)
+	require.Equal(t, []interface{}{"new", 100, 1}, c.Args)
+
+	require.Equal(t, sqlf.Expr("id = ?", 42), sqlf.Expr("id = ?", 42).And())
+}
+
+func TestWhereCond(t *testing.T) {
+	q := sqlf.PostgreSQL.Update("orders").
+		SetCond("amount", sqlf.Expr("amount + ?", 10)).
+		WhereCond(sqlf.Expr("status = ?", "new").Or(sqlf.Expr("user_id = ?", 1)))
+	defer q.Close()
+	require.Equal(t, "UPDATE orders SET amount=amount + $1 WHERE (status = $2 OR user_id = $3)", q.String())
+	require.Equal(t, []interface{}{10, "new", 1}, q.Args())
+
+	q2 := sqlf.From("orders").
+		Select("user_id").
+		GroupBy("user_id").
+		HavingCond(sqlf.Expr("SUM(amount) > ?", 100))
+	defer q2.Close()
+	require.Equal(t, "SELECT user_id FROM orders GROUP BY user_id HAVING SUM(amount) > ?", q2.String())
+}
+
+func TestFunctions(t *testing.T) {
+	c := sqlf.Coalesce(sqlf.Expr("nickname"), sqlf.Expr("name"), "anonymous")
+	require.Equal(t, "COALESCE(nickname, name, ?)", c.Expr)
+	require.Equal(t, []interface{}{"anonymous"}, c.Args)
+
+	q := sqlf.PostgreSQL.Update("accounts").
+		SetCond("balance", sqlf.Greatest(sqlf.Expr("balance - ?", 10), 0)).
+		Where("id = ?", 42)
+	defer q.Close()
+	require.Equal(t, "UPDATE accounts SET balance=GREATEST(balance - $1, $2) WHERE id = $3", q.String())
+	require.Equal(t, []interface{}{10, 0, 42}, q.Args())
+
+	least := sqlf.Least(sqlf.Expr("limit_a"), sqlf.Expr("limit_b"), 100)
+	q2 := sqlf.From("accounts").Select(least.Expr, least.Args...)
+	defer q2.Close()
+	require.Equal(t, "SELECT LEAST(limit_a, limit_b, ?) FROM accounts", q2.String())
+}
+
+func TestAggFilter(t *testing.T) {
+	paid := sqlf.AggFilter("COUNT(*)", "status = ?", "paid")
+	total := sqlf.AggFilter("SUM(amount)", "created_at > ? AND region = ?", "2024-01-01", "eu")
+	q := sqlf.PostgreSQL.From("orders").
+		Select("user_id").
+		Select(paid.Expr, paid.Args...).
+		Select(total.Expr, total.Args...).
+		Where("user_id > ?", 10).
+		GroupBy("user_id")
+	defer q.Close()
+	require.Equal(t, "SELECT user_id, COUNT(*) FILTER (WHERE status = $1), SUM(amount) FILTER (WHERE created_at > $2 AND region = $3) FROM orders WHERE user_id > $4 GROUP BY user_id", q.String())
+	require.Equal(t, []interface{}{"paid", "2024-01-01", "eu", 10}, q.Args())
+}
+
+func TestCase(t *testing.T) {
+	c := sqlf.Case().
+		When("amount > ?", "?", 1000, "large").
+		When("amount > ?", "?", 100, "medium").
+		Else("?", "small").
+		As("size").
+		Cond()
+	q := sqlf.PostgreSQL.From("orders").
+		Select("id").
+		Select(c.Expr, c.Args...).
+		Where("user_id = ?", 42)
+	defer q.Close()
+	require.Equal(t, "SELECT id, CASE WHEN amount > $1 THEN $2 WHEN amount > $3 THEN $4 ELSE $5 END AS size FROM orders WHERE user_id = $6", q.String())
+	require.Equal(t, []interface{}{1000, "large", 100, "medium", "small", 42}, q.Args())
+
+	q2 := sqlf.Update("orders").
+		SetCond("status", sqlf.Case().When("paid_at IS NOT NULL", "'paid'").Else("status").Cond())
+	defer q2.Close()
+	require.Equal(t, "UPDATE orders SET status=CASE WHEN paid_at IS NOT NULL THEN 'paid' ELSE status END", q2.String())
+}
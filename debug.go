@@ -0,0 +1,148 @@
+package sqlf
+
+import (
+	"database/sql/driver"
+	"encoding/hex"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+/*
+DebugSQL returns an SQL statement with arguments embedded as literals.
+
+It's meant for logging and debugging only:
+
+	q := sqlf.PostgreSQL.From("users").
+		Select("id").
+		Where("name = ?", "O'Brien").
+		Where("created_at > ?", since)
+	log.Println(q.DebugSQL())
+
+prints
+
+	SELECT id FROM users WHERE name = 'O''Brien' AND created_at > '2020-01-02T15:04:05Z'
+
+Never execute the returned statement, use String and Args instead.
+Time values are formatted with a layout set by Dialect.SetTimeLayout.
+Arguments are passed through a function set by Dialect.SetArgMasker.
+*/
+func (q *Stmt) DebugSQL() string {
+	if q.pendingHook() {
+		// Don't alter a statement being inspected
+		c := q.Clone()
+		defer c.Close()
+		c.applyBuildHook()
+		return c.DebugSQL()
+	}
+	var buf strings.Builder
+	args := q.args
+	if mask := q.dialect.argMasker; mask != nil {
+		args = make([]interface{}, len(q.args))
+		for i, arg := range q.args {
+			args[i] = mask(i, arg)
+		}
+	}
+	pos := chunkPos(0)
+	argNo := 0
+	for n, chunk := range q.chunks {
+		if n > 0 && chunk.pos > pos {
+			buf.Write(space)
+		}
+		s := bufToString(&q.buf.B)[chunk.bufLow:chunk.bufHigh]
+		if chunk.argLen > 0 || chunk.escaped {
+			argNo = q.writeDebug(&buf, s, args, argNo)
+		} else {
+			buf.WriteString(s)
+		}
+		pos = chunk.pos
+	}
+	return buf.String()
+}
+
+// writeDebug writes s to buf replacing ? placeholders with args
+// starting from argNo. It returns an index of the next argument.
+func (q *Stmt) writeDebug(buf *strings.Builder, s string, args []interface{}, argNo int) int {
+	start := 0
+	for i := 0; i < len(s); i++ {
+		switch s[i] {
+		case '\\':
+			if i < len(s)-1 && s[i+1] == '?' {
+				buf.WriteString(s[start:i])
+				start = i + 1
+				i++
+			}
+		case '?':
+			if dist, n := parseBackRef(s[i:]); n > 0 {
+				buf.WriteString(s[start:i])
+				q.writeLiteral(buf, args[argNo-dist])
+				start = i + n
+				i += n - 1
+				continue
+			}
+			if argNo >= len(args) {
+				continue
+			}
+			buf.WriteString(s[start:i])
+			q.writeLiteral(buf, args[argNo])
+			argNo++
+			start = i + 1
+		}
+	}
+	buf.WriteString(s[start:])
+	return argNo
+}
+
+// writeLiteral writes an argument value as an SQL literal.
+func (q *Stmt) writeLiteral(buf *strings.Builder, arg interface{}) {
+	if v, ok := arg.(driver.Valuer); ok {
+		value, err := v.Value()
+		if err != nil {
+			buf.WriteString("NULL")
+			return
+		}
+		arg = value
+	}
+	switch v := arg.(type) {
+	case nil:
+		buf.WriteString("NULL")
+	case string:
+		writeQuoted(buf, v)
+	case []byte:
+		if q.dialect == PostgreSQL {
+			buf.WriteString(`'\x`)
+		} else {
+			buf.WriteString("X'")
+		}
+		buf.WriteString(hex.EncodeToString(v))
+		buf.WriteByte('\'')
+	case bool:
+		if v {
+			buf.WriteString("TRUE")
+		} else {
+			buf.WriteString("FALSE")
+		}
+	case int, int8, int16, int32, int64, uint, uint8, uint16, uint32, uint64:
+		fmt.Fprint(buf, v)
+	case float32:
+		buf.WriteString(strconv.FormatFloat(float64(v), 'g', -1, 32))
+	case float64:
+		buf.WriteString(strconv.FormatFloat(v, 'g', -1, 64))
+	case time.Time:
+		layout := q.dialect.timeLayout
+		if layout == "" {
+			layout = time.RFC3339
+		}
+		writeQuoted(buf, v.Format(layout))
+	default:
+		writeQuoted(buf, fmt.Sprint(v))
+	}
+}
+
+// writeQuoted writes a string literal doubling single quotes.
+func writeQuoted(buf *strings.Builder, s string) {
+	buf.WriteByte('\'')
+	buf.WriteString(strings.Replace(s, "'", "''", -1))
+	buf.WriteByte('\'')
+}
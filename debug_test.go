@@ -0,0 +1,73 @@
+package sqlf_test
+
+import (
+	"database/sql"
+	"testing"
+	"time"
+
+	"github.com/leporo/sqlf"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDebugSQL(t *testing.T) {
+	ts := time.Date(2020, 1, 2, 15, 4, 5, 0, time.UTC)
+	q := sqlf.PostgreSQL.From("users").
+		Select("id").
+		Where("name = ?", "O'Brien").
+		Where("created_at > ?", ts).
+		Where("is_active = ? AND deleted_at IS ?", true, nil).
+		WhereRaw("tags ? 'admin'").
+		Where("score > ?", 1.5).
+		Where("nickname = ?", sql.NullString{}).
+		Limit(10)
+	defer q.Close()
+
+	require.Equal(t, "SELECT id FROM users WHERE name = 'O''Brien' AND created_at > '2020-01-02T15:04:05Z' AND is_active = TRUE AND deleted_at IS NULL AND tags ? 'admin' AND score > 1.5 AND nickname = NULL LIMIT 10", q.DebugSQL())
+	// DebugSQL doesn't alter a statement
+	require.Equal(t, "SELECT id FROM users WHERE name = $1 AND created_at > $2 AND is_active = $3 AND deleted_at IS $4 AND tags ? 'admin' AND score > $5 AND nickname = $6 LIMIT $7", q.String())
+}
+
+func TestDebugSQLTimeLayout(t *testing.T) {
+	d := &sqlf.Dialect{}
+	d.SetTimeLayout("2006-01-02 15:04:05")
+
+	q := d.From("orders").
+		Select("id").
+		Where("created_at > ?", time.Date(2020, 1, 2, 15, 4, 5, 0, time.UTC)).
+		Where("hash = ?", []byte{0xca, 0xfe})
+	defer q.Close()
+	require.Equal(t, "SELECT id FROM orders WHERE created_at > '2020-01-02 15:04:05' AND hash = X'cafe'", q.DebugSQL())
+
+	q2 := sqlf.MySQL.From("orders").
+		Select("id").
+		Where("created_at > ?", time.Date(2020, 1, 2, 15, 4, 5, 0, time.UTC))
+	defer q2.Close()
+	require.Equal(t, "SELECT id FROM orders WHERE created_at > '2020-01-02 15:04:05'", q2.DebugSQL())
+}
+
+func TestDebugSQLManyArgs(t *testing.T) {
+	q := sqlf.PostgreSQL.From("t").Select("id")
+	defer q.Close()
+	for i := 1; i <= 12; i++ {
+		q.Where("c = ?", i)
+	}
+	require.Equal(t, "SELECT id FROM t WHERE c = 1 AND c = 2 AND c = 3 AND c = 4 AND c = 5 AND c = 6 AND c = 7 AND c = 8 AND c = 9 AND c = 10 AND c = 11 AND c = 12", q.DebugSQL())
+}
+
+func TestDebugSQLArgMasker(t *testing.T) {
+	d := &sqlf.Dialect{}
+	d.SetArgMasker(func(i int, v interface{}) interface{} {
+		if i == 1 {
+			return "***"
+		}
+		return v
+	})
+
+	q := d.Update("users").
+		Set("email", "user@example.com").
+		Set("password", "secret").
+		Where("id = ?", 42)
+	defer q.Close()
+	require.Equal(t, "UPDATE users SET email='user@example.com', password='***' WHERE id = 42", q.DebugSQL())
+	require.Equal(t, []interface{}{"user@example.com", "secret", 42}, q.Args())
+}
@@ -1,6 +1,9 @@
 package sqlf
 
 import (
+	"errors"
+	"fmt"
+	"io"
 	"strconv"
 	"strings"
 	"sync"
@@ -28,9 +31,13 @@ import (
 // When PostgreSQL mode is activated, ? placeholders are
 // replaced with numbered positional arguments like $1, $2...
 type Dialect struct {
-	cacheOnce sync.Once
-	cacheLock sync.RWMutex
-	cache     sqlCache
+	cacheOnce  sync.Once
+	cacheLock  sync.RWMutex
+	cache      sqlCache
+	onBuild    atomic.Value // holds a buildHook
+	timeLayout string
+	quotes     [2]byte
+	argMasker  func(i int, v interface{}) interface{}
 }
 
 var (
@@ -38,14 +45,22 @@ var (
 	NoDialect *Dialect = &Dialect{}
 	// PostgreSQL mode is to be used to automatically replace ? placeholders with $1, $2...
 	PostgreSQL *Dialect = &Dialect{}
+	// MySQL mode keeps ? placeholders and enables MySQL-specific SQL fragments.
+	MySQL *Dialect = &Dialect{timeLayout: "2006-01-02 15:04:05", quotes: [2]byte{'`', '`'}}
+	// SQLite mode keeps ? placeholders and enables SQLite-specific SQL fragments.
+	SQLite *Dialect = &Dialect{}
 )
 
 var defaultDialect = NoDialect
 
+// subQuery mode renders statements to be embedded into other ones.
+// Unlike NoDialect, it keeps escaped question marks as is.
+var subQuery = &Dialect{}
+
 /*
 SetDialect selects a Dialect to be used by default.
 
-Dialect can be one of sqlf.NoDialect or sqlf.PostgreSQL
+Dialect can be one of sqlf.NoDialect, sqlf.PostgreSQL, sqlf.MySQL or sqlf.SQLite
 
 	sqlf.SetDialect(sqlf.PostgreSQL)
 */
@@ -53,6 +68,116 @@ func SetDialect(newDefaultDialect *Dialect) {
 	atomic.StorePointer((*unsafe.Pointer)(unsafe.Pointer(&defaultDialect)), unsafe.Pointer(newDefaultDialect))
 }
 
+/*
+DefaultDialect returns a Dialect used by default.
+
+Use it to restore a default dialect after changing it:
+
+	saved := sqlf.DefaultDialect()
+	sqlf.SetDialect(sqlf.PostgreSQL)
+	defer sqlf.SetDialect(saved)
+*/
+func DefaultDialect() *Dialect {
+	return (*Dialect)(atomic.LoadPointer((*unsafe.Pointer)(unsafe.Pointer(&defaultDialect))))
+}
+
+/*
+OnBuild sets a function to be called right before a statement
+of the dialect is built.
+
+The function may alter a statement, for example, add a filter
+to every query:
+
+	sqlf.PostgreSQL.OnBuild(func(q *sqlf.Stmt) {
+		q.Where("tenant_id = ?", tenantID)
+	})
+
+The function is called once per statement by String, WriteTo
+or a query execution method, whichever comes first. Peek and DebugSQL
+call it for a copy of a statement, leaving the statement intact.
+A subquery gets a function of a dialect it's built for when it's
+added to another statement.
+Clauses added by the function are kept when a statement is modified
+and built again. The function must not call String or other methods
+that build the statement.
+
+OnBuild is safe for concurrent use. Pass nil to remove the function.
+*/
+func (b *Dialect) OnBuild(fn func(q *Stmt)) {
+	b.onBuild.Store(buildHook(fn))
+}
+
+// buildHook is a function set by OnBuild.
+type buildHook func(q *Stmt)
+
+// buildHook returns a function set by OnBuild or nil.
+func (b *Dialect) buildHook() buildHook {
+	fn, _ := b.onBuild.Load().(buildHook)
+	return fn
+}
+
+/*
+SetTimeLayout sets a layout DebugSQL uses to format time.Time arguments
+of statements of the dialect. The default layout of MySQL dialect
+is "2006-01-02 15:04:05", as MySQL rejects RFC 3339 values in strict mode.
+Other dialects default to time.RFC3339.
+
+Use a layout a database understands to get SQL that can be pasted
+to a database console:
+
+	sqlf.MySQL.SetTimeLayout("2006-01-02 15:04:05.999999")
+
+Set it during the program initialization, SetTimeLayout is not safe
+for concurrent use.
+*/
+func (b *Dialect) SetTimeLayout(layout string) {
+	b.timeLayout = layout
+}
+
+/*
+SetIdentifierQuotes sets characters QuoteIdentifier encloses names in.
+MySQL dialect uses backticks by default, other dialects use double quotes.
+
+Use it to quote identifiers with brackets for SQL Server:
+
+	mssql := &sqlf.Dialect{}
+	mssql.SetIdentifierQuotes('[', ']')
+	mssql.QuoteIdentifier("my]table") // [my]]table]
+
+Set it during the program initialization, SetIdentifierQuotes is not safe
+for concurrent use.
+*/
+func (b *Dialect) SetIdentifierQuotes(left, right byte) {
+	b.quotes = [2]byte{left, right}
+}
+
+/*
+SetArgMasker sets a function DebugSQL applies to every argument
+of statements of the dialect before embedding it into SQL.
+
+Use it to hide secrets from logs:
+
+	sqlf.PostgreSQL.SetArgMasker(func(i int, v interface{}) interface{} {
+		if _, ok := v.(Secret); ok {
+			return "***"
+		}
+		return v
+	})
+
+i is an argument index. Arguments passed to a database driver
+are not affected. Set it during the program initialization,
+SetArgMasker is not safe for concurrent use.
+*/
+func (b *Dialect) SetArgMasker(fn func(i int, v interface{}) interface{}) {
+	b.argMasker = fn
+}
+
+// numbered reports if a dialect renders back references to arguments
+// as numbered placeholders, see backRef.
+func (b *Dialect) numbered() bool {
+	return b == PostgreSQL || b == SQLite
+}
+
 /*
 New starts an SQL statement with an arbitrary verb.
 
@@ -61,6 +186,7 @@ an instance of an SQL statement builder for common statements.
 */
 func (b *Dialect) New(verb string, args ...interface{}) *Stmt {
 	q := getStmt(b)
+	verb, args = q.indexArgs(verb, args)
 	q.addChunk(posSelect, verb, "", args, ", ")
 	return q
 }
@@ -74,6 +200,15 @@ func (b *Dialect) With(queryName string, query *Stmt) *Stmt {
 	return q.With(queryName, query)
 }
 
+/*
+WithRecursive starts a statement prepended by a recursive WITH clause
+and closes subqueries passed as arguments.
+*/
+func (b *Dialect) WithRecursive(queryName string, base, recursive *Stmt, all bool) *Stmt {
+	q := getStmt(b)
+	return q.WithRecursive(queryName, base, recursive, all)
+}
+
 /*
 From starts a SELECT statement.
 */
@@ -105,18 +240,101 @@ func (b *Dialect) InsertInto(tableName string) *Stmt {
 	return q.InsertInto(tableName)
 }
 
+// ReplaceInto starts a REPLACE statement.
+func (b *Dialect) ReplaceInto(tableName string) *Stmt {
+	q := getStmt(b)
+	return q.ReplaceInto(tableName)
+}
+
 // DeleteFrom starts a DELETE statement.
 func (b *Dialect) DeleteFrom(tableName string) *Stmt {
 	q := getStmt(b)
 	return q.DeleteFrom(tableName)
 }
 
+/*
+QuoteIdentifier quotes a table or a column name to be safely
+used in an SQL statement.
+
+MySQL dialect encloses name in backticks, other dialects use double quotes
+unless other quote characters are set with SetIdentifierQuotes.
+Closing quote characters embedded into name are doubled:
+
+	sqlf.PostgreSQL.QuoteIdentifier(`my "table"`) // "my ""table"""
+
+Dots separate parts of a qualified name, every part is quoted separately:
+
+	sqlf.PostgreSQL.QuoteIdentifier("public.users") // "public"."users"
+*/
+func (b *Dialect) QuoteIdentifier(name string) string {
+	left, right := b.quotes[0], b.quotes[1]
+	if left == 0 {
+		left, right = '"', '"'
+	}
+	buf := strings.Builder{}
+	buf.Grow(len(name) + 2)
+	buf.WriteByte(left)
+	for i := 0; i < len(name); i++ {
+		switch name[i] {
+		case right:
+			buf.WriteByte(right)
+		case '.':
+			// Close a quoted part and start the next one
+			buf.WriteByte(right)
+			buf.WriteByte('.')
+			buf.WriteByte(left)
+			continue
+		}
+		buf.WriteByte(name[i])
+	}
+	buf.WriteByte(right)
+	return buf.String()
+}
+
+/*
+Values builds a VALUES list to be used as a subquery.
+
+Every row is rendered as a tuple of len(columns) placeholders.
+Values records an error if there are no columns or rows, or a row
+has a number of values that doesn't match the number of columns, see Stmt.Err.
+*/
+func (b *Dialect) Values(columns []string, rows [][]interface{}) *Stmt {
+	q := getStmt(b)
+	q.addChunk(posSelect, "VALUES", "", nil, "")
+	n := len(columns)
+	switch {
+	case n == 0:
+		q.setErr(errors.New("sqlf: Values is called with no columns"))
+		return q
+	case len(rows) == 0:
+		q.setErr(errors.New("sqlf: Values is called with no rows"))
+		return q
+	}
+	tuple := "(" + strings.Repeat("?, ", n-1) + "?)"
+	for i, row := range rows {
+		if len(row) != n {
+			q.setErr(fmt.Errorf("sqlf: Values row %d has %d value(s) for %d column(s)", i+1, len(row), n))
+			return q
+		}
+		q.addChunk(posSelect, "", tuple, row, ", ")
+	}
+	return q
+}
+
+// sqlWriter is implemented by buffers an SQL statement can be rendered into.
+type sqlWriter interface {
+	io.Writer
+	io.ByteWriter
+	io.StringWriter
+}
+
 // writePg function copies s into buf and replaces ? placeholders with $1, $2...
-func writePg(argNo int, s []byte, buf *strings.Builder) (int, error) {
+func writePg(argNo int, s []byte, buf sqlWriter) (int, error) {
 	var err error
 	start := 0
+	str := bufToString(&s)
 	// Iterate by runes
-	for pos, r := range bufToString(&s) {
+	for pos, r := range str {
 		if start > pos {
 			continue
 		}
@@ -134,10 +352,17 @@ func writePg(argNo int, s []byte, buf *strings.Builder) (int, error) {
 			start = pos + 1
 			if err == nil {
 				err = buf.WriteByte('$')
-				if err == nil {
-					buf.WriteString(strconv.Itoa(argNo))
-					argNo++
+				if err != nil {
+					break
+				}
+				// Refer to an argument passed already
+				if dist, n := parseBackRef(str[pos:]); n > 0 {
+					buf.WriteString(strconv.Itoa(argNo - dist))
+					start = pos + n
+					break
 				}
+				buf.WriteString(strconv.Itoa(argNo))
+				argNo++
 			}
 		}
 		if err != nil {
@@ -149,3 +374,39 @@ func writePg(argNo int, s []byte, buf *strings.Builder) (int, error) {
 	}
 	return argNo, err
 }
+
+// writeUnescaped function copies s into buf replacing escaped \? with ?.
+func writeUnescaped(s []byte, buf sqlWriter) {
+	start := 0
+	for i := 0; i < len(s)-1; i++ {
+		if s[i] == '\\' && s[i+1] == '?' {
+			buf.Write(s[start:i])
+			start = i + 1
+			i++
+		}
+	}
+	buf.Write(s[start:])
+}
+
+// writeSQLite function copies s into buf and replaces back references
+// to arguments with ?N placeholders.
+func writeSQLite(argNo int, s []byte, buf sqlWriter) int {
+	start := 0
+	str := bufToString(&s)
+	for pos := 0; pos < len(str); pos++ {
+		if str[pos] != '?' {
+			continue
+		}
+		dist, n := parseBackRef(str[pos:])
+		if n == 0 {
+			argNo++
+			continue
+		}
+		buf.Write(s[start : pos+1])
+		buf.WriteString(strconv.Itoa(argNo - dist))
+		start = pos + n
+		pos += n - 1
+	}
+	buf.Write(s[start:])
+	return argNo
+}
@@ -0,0 +1,76 @@
+package sqlf_test
+
+import (
+	"testing"
+
+	"github.com/leporo/sqlf"
+	"github.com/stretchr/testify/require"
+)
+
+func TestQuoteIdentifier(t *testing.T) {
+	require.Equal(t, `"users"`, sqlf.NoDialect.QuoteIdentifier("users"))
+	require.Equal(t, `"my ""table"""`, sqlf.PostgreSQL.QuoteIdentifier(`my "table"`))
+	require.Equal(t, "`my ``table```", sqlf.MySQL.QuoteIdentifier("my `table`"))
+	require.Equal(t, `"public"."users"`, sqlf.PostgreSQL.QuoteIdentifier("public.users"))
+	require.Equal(t, `"schema"."table"."column"`, sqlf.PostgreSQL.QuoteIdentifier("schema.table.column"))
+	require.Equal(t, "`db`.`users`", sqlf.MySQL.QuoteIdentifier("db.users"))
+
+	mssql := &sqlf.Dialect{}
+	mssql.SetIdentifierQuotes('[', ']')
+	require.Equal(t, "[dbo].[my]]table]", mssql.QuoteIdentifier("dbo.my]table"))
+}
+
+func TestDefaultDialect(t *testing.T) {
+	saved := sqlf.DefaultDialect()
+	defer sqlf.SetDialect(saved)
+
+	sqlf.SetDialect(sqlf.PostgreSQL)
+	require.Equal(t, sqlf.PostgreSQL, sqlf.DefaultDialect())
+
+	q := sqlf.From("table").Select("id").Where("id = ?", 42)
+	defer q.Close()
+	require.Equal(t, "SELECT id FROM table WHERE id = $1", q.String())
+}
+
+func TestOnBuild(t *testing.T) {
+	d := &sqlf.Dialect{}
+	calls := 0
+	d.OnBuild(func(q *sqlf.Stmt) {
+		calls++
+		q.Where("tenant_id = ?", 7)
+	})
+
+	q := d.From("users").Select("id").Where("id = ?", 42)
+	defer q.Close()
+	require.Equal(t, "SELECT id FROM users WHERE id = ? AND tenant_id = ?", q.String())
+	require.Equal(t, "SELECT id FROM users WHERE id = ? AND tenant_id = ?", q.String())
+	require.Equal(t, 1, calls)
+	require.Equal(t, []interface{}{42, 7}, q.Args())
+
+	q.Invalidate()
+	require.Equal(t, "SELECT id FROM users WHERE id = ? AND tenant_id = ?", q.String())
+	q.Where("name = ?", "Ann")
+	require.Equal(t, "SELECT id FROM users WHERE id = ? AND tenant_id = ? AND name = ?", q.String())
+	require.Equal(t, []interface{}{42, 7, "Ann"}, q.Args())
+	require.Equal(t, 1, calls)
+
+	q3 := d.From("users").Select("id")
+	defer q3.Close()
+	require.Equal(t, "SELECT id FROM users WHERE tenant_id = 7", q3.DebugSQL())
+	require.Equal(t, 2, calls)
+	sql, args := q3.Peek()
+	require.Equal(t, "SELECT id FROM users WHERE tenant_id = ?", sql)
+	require.Equal(t, []interface{}{7}, args)
+	require.Equal(t, 3, calls)
+
+	// Inspected statements get the filter once built
+	q3.Where("name = ?", "Ann")
+	require.Equal(t, "SELECT id FROM users WHERE name = ? AND tenant_id = ?", q3.String())
+	require.Equal(t, []interface{}{"Ann", 7}, q3.Args())
+	require.Equal(t, 4, calls)
+
+	d.OnBuild(nil)
+	q2 := d.From("users").Select("id")
+	defer q2.Close()
+	require.Equal(t, "SELECT id FROM users", q2.String())
+}
@@ -10,5 +10,40 @@ sqlf statement builder provides a way to:
 - Map columns to variables to be referenced by Scan,
 
 - Convert ? placeholders into numbered ones for PostgreSQL ($1, $2, etc).
+
+Named Arguments
+
+sql.NamedArg values are passed to a database driver as is. Use a named
+placeholder form supported by a driver in SQL fragments:
+
+	q := sqlf.From("users").
+		Select("name").
+		Where("id = @id", sql.Named("id", 42))
+
+Placeholder numbering of PostgreSQL dialect counts ? placeholders only,
+so don't mix named and ? placeholders in a PostgreSQL statement.
+
+Indexed Placeholders
+
+A ?N placeholder refers to N-th argument of an SQL fragment it belongs to.
+Use it to reference an argument more than once:
+
+	q := sqlf.PostgreSQL.From("events").
+		Select("id").
+		Where("starts_at <= ?1 AND ends_at >= ?1", now)
+
+produces
+
+	SELECT id FROM events WHERE starts_at <= $1 AND ends_at >= $1
+
+with now passed once. SQLite statements get ?N placeholders the same way.
+Other dialects don't support numbered placeholders, so an argument
+is passed as many times as it's referenced.
+
+Indexed placeholders are supported by every method accepting an SQL
+fragment along with its arguments, like Where, From, Expr or Clause,
+and can be mixed with ? placeholders within a fragment. A ? placeholder refers to the argument next
+to the one with the largest index used so far. A placeholder referring
+to a missing argument is recorded as an error, see Stmt.Err.
 */
 package sqlf
@@ -3,8 +3,68 @@ package sqlf
 import (
 	"context"
 	"database/sql"
+	"errors"
+	"fmt"
+	"reflect"
+	"sync/atomic"
+	"time"
 )
 
+// ErrNotFound is returned by GetOrNotFound method when a query returns no rows.
+//
+// ErrNotFound wraps sql.ErrNoRows, so errors.Is(err, sql.ErrNoRows)
+// is true for it as well.
+var ErrNotFound = fmt.Errorf("sqlf: not found: %w", sql.ErrNoRows)
+
+// MaxPostgreSQLArgs is the maximum number of arguments
+// PostgreSQL accepts for a single statement.
+const MaxPostgreSQLArgs = 65535
+
+// TooManyArgsError is returned on an attempt to execute a statement
+// having more arguments than a database accepts.
+// Split bulk INSERT statements into batches to avoid it.
+type TooManyArgsError struct {
+	Args  int
+	Limit int
+}
+
+func (e *TooManyArgsError) Error() string {
+	return fmt.Sprintf("sqlf: statement has %d arguments, the limit is %d; split it into batches", e.Args, e.Limit)
+}
+
+var dryRun int32
+
+/*
+SetDryRun turns the dry run mode on or off.
+
+In dry run mode statements are not sent to a database:
+
+- Exec returns a result reporting no affected rows,
+
+- Query returns no rows and never calls a handler function,
+
+- QueryRow returns sql.ErrNoRows.
+
+Use it to check generated SQL in tests without a live database.
+*/
+func SetDryRun(on bool) {
+	var v int32
+	if on {
+		v = 1
+	}
+	atomic.StoreInt32(&dryRun, v)
+}
+
+func isDryRun() bool {
+	return atomic.LoadInt32(&dryRun) != 0
+}
+
+// dryRunResult is an sql.Result returned by Exec in dry run mode.
+type dryRunResult struct{}
+
+func (dryRunResult) LastInsertId() (int64, error) { return 0, nil }
+func (dryRunResult) RowsAffected() (int64, error) { return 0, nil }
+
 // Executor performs SQL queries.
 // It's an interface accepted by Query, QueryRow and Exec methods.
 // Both sql.DB, sql.Conn and sql.Tx can be passed as executor.
@@ -19,23 +79,43 @@ type Executor interface {
 // If scan targets were set via To method calls, Query method
 // executes rows.Scan right before calling a handler function.
 func (q *Stmt) Query(ctx context.Context, db Executor, handler func(rows *sql.Rows)) error {
+	if err := q.check(); err != nil {
+		return err
+	}
+	if isDryRun() {
+		return nil
+	}
 	if ctx == nil {
 		ctx = context.Background()
 	}
 
-	// Fetch rows
-	rows, err := db.QueryContext(ctx, q.String(), q.args...)
+	// Fetch rows. Build a statement first, as it may alter arguments.
+	query := q.String()
+	rows, err := db.QueryContext(ctx, query, q.args...)
 	if err != nil {
 		return err
 	}
+	// Release a connection even if a handler panics.
+	// Close does nothing if rows are closed already.
+	defer rows.Close()
+
+	dest := discardNil(q.dest)
+	if q.destStruct != nil {
+		dest, err = q.namedDest(rows)
+	}
+	store := func() {}
+	if q.nullAsZero {
+		dest, store = nullable(dest)
+	}
 
 	// Iterate through rows of returned dataset
-	for rows.Next() {
-		if len(q.dest) > 0 {
-			err = rows.Scan(q.dest...)
+	for err == nil && rows.Next() {
+		if len(dest) > 0 {
+			err = rows.Scan(dest...)
 			if err != nil {
 				break
 			}
+			store()
 		}
 		// Call a callback function
 		handler(rows)
@@ -44,16 +124,47 @@ func (q *Stmt) Query(ctx context.Context, db Executor, handler func(rows *sql.Ro
 	// This may be more important if multiple statements are executed
 	// in a single batch and rows were written as well as read.
 	if closeErr := rows.Close(); closeErr != nil {
-		return closeErr
+		err = closeErr
+	}
+
+	// Check for errors during row iteration.
+	if err == nil {
+		err = rows.Err()
 	}
 
-	// Check for row scan error.
+	// Report a cancellation rather than an error it caused
+	if err != nil && ctx.Err() != nil {
+		return ctx.Err()
+	}
+	return err
+}
+
+// namedDest returns scan targets for columns of a returned dataset
+// matching fields of a structure passed to ReturningAll.
+func (q *Stmt) namedDest(rows *sql.Rows) ([]interface{}, error) {
+	cols, err := rows.Columns()
 	if err != nil {
-		return err
+		return nil, err
 	}
+	return structDest(cols, q.destStruct), nil
+}
 
-	// Check for errors during row iteration.
-	return rows.Err()
+// structDest returns scan targets for columns matching fields
+// of a structure data points to. Values of other columns are discarded.
+func structDest(cols []string, data interface{}) []interface{} {
+	fields := make(map[string]interface{}, len(cols))
+	walkFields(data, func(name string, ptr interface{}) {
+		fields[name] = ptr
+	})
+	dest := make([]interface{}, len(cols))
+	for i, col := range cols {
+		if ptr, ok := fields[col]; ok {
+			dest[i] = ptr
+		} else {
+			dest[i] = new(sql.RawBytes)
+		}
+	}
+	return dest
 }
 
 // QueryAndClose executes the statement and releases all the resources that
@@ -67,15 +178,198 @@ func (q *Stmt) QueryAndClose(ctx context.Context, db Executor, handler func(rows
 	return err
 }
 
+/*
+QueryScalars executes the statement and appends values of a single
+returned column to a slice dest points to:
+
+	var ids []int64
+	err := sqlf.From("users").
+		Select("id").
+		Where("is_active = ?", true).
+		QueryScalars(ctx, db, &ids)
+
+QueryScalars returns an error if a query returns more than one column.
+Scan targets bound via To method calls are ignored.
+*/
+func (q *Stmt) QueryScalars(ctx context.Context, db Executor, dest interface{}) error {
+	v := reflect.ValueOf(dest)
+	if v.Kind() != reflect.Ptr || v.Elem().Kind() != reflect.Slice {
+		return errors.New("sqlf: QueryScalars dest must be a pointer to a slice")
+	}
+	slice := v.Elem()
+
+	if err := q.check(); err != nil {
+		return err
+	}
+	if isDryRun() {
+		return nil
+	}
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	query := q.String()
+	rows, err := db.QueryContext(ctx, query, q.args...)
+	if err != nil {
+		return err
+	}
+
+	cols, err := rows.Columns()
+	if err == nil && len(cols) != 1 {
+		err = fmt.Errorf("sqlf: QueryScalars expects a single column, got %d", len(cols))
+	}
+	if err == nil {
+		for rows.Next() {
+			value := reflect.New(slice.Type().Elem())
+			err = rows.Scan(value.Interface())
+			if err != nil {
+				break
+			}
+			slice = reflect.Append(slice, value.Elem())
+		}
+		v.Elem().Set(slice)
+	}
+	if closeErr := rows.Close(); closeErr != nil {
+		return closeErr
+	}
+	if err != nil {
+		return err
+	}
+	return rows.Err()
+}
+
+/*
+QueryReturning executes the statement and appends every returned row
+to a slice dest points to.
+
+Use it to fetch rows returned by INSERT, UPDATE or DELETE statements
+with a RETURNING clause:
+
+	var updated []Order
+	err := sqlf.PostgreSQL.Update("orders").
+		Set("status", "shipped").
+		Where("batch_id = ?", batchID).
+		Returning("*").
+		QueryReturning(ctx, db, &updated)
+
+Slice elements can be structures or pointers to structures annotated
+with "db" tags. Columns are matched to fields by name, columns without
+matching fields are ignored. A slice of any other type is populated
+the way QueryScalars does it.
+Scan targets bound via To method calls are ignored.
+*/
+func (q *Stmt) QueryReturning(ctx context.Context, db Executor, dest interface{}) error {
+	v := reflect.ValueOf(dest)
+	if v.Kind() != reflect.Ptr || v.Elem().Kind() != reflect.Slice {
+		return errors.New("sqlf: QueryReturning dest must be a pointer to a slice")
+	}
+	slice := v.Elem()
+	elemType := slice.Type().Elem()
+	isPtr := elemType.Kind() == reflect.Ptr
+	if isPtr {
+		elemType = elemType.Elem()
+	}
+	if elemType.Kind() != reflect.Struct {
+		return q.QueryScalars(ctx, db, dest)
+	}
+
+	if err := q.check(); err != nil {
+		return err
+	}
+	if isDryRun() {
+		return nil
+	}
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	query := q.String()
+	rows, err := db.QueryContext(ctx, query, q.args...)
+	if err != nil {
+		return err
+	}
+
+	cols, err := rows.Columns()
+	if err == nil {
+		for rows.Next() {
+			value := reflect.New(elemType)
+			err = rows.Scan(structDest(cols, value.Interface())...)
+			if err != nil {
+				break
+			}
+			if isPtr {
+				slice = reflect.Append(slice, value)
+			} else {
+				slice = reflect.Append(slice, value.Elem())
+			}
+		}
+		v.Elem().Set(slice)
+	}
+	if closeErr := rows.Close(); closeErr != nil {
+		return closeErr
+	}
+	if err != nil {
+		return err
+	}
+	return rows.Err()
+}
+
 // QueryRow executes the statement via Executor methods
 // and scans values to variables bound via To method calls.
 func (q *Stmt) QueryRow(ctx context.Context, db Executor) error {
+	if err := q.check(); err != nil {
+		return err
+	}
+	if isDryRun() {
+		return sql.ErrNoRows
+	}
 	if ctx == nil {
 		ctx = context.Background()
 	}
-	row := db.QueryRowContext(ctx, q.String(), q.args...)
+	query := q.String()
+	if q.destStruct != nil {
+		return q.queryStructRow(ctx, db, query)
+	}
+	row := db.QueryRowContext(ctx, query, q.args...)
 
-	return row.Scan(q.dest...)
+	dest := discardNil(q.dest)
+	if !q.nullAsZero {
+		return row.Scan(dest...)
+	}
+	dest, store := nullable(dest)
+	err := row.Scan(dest...)
+	if err == nil {
+		store()
+	}
+	return err
+}
+
+// queryStructRow executes a query and scans the first returned row
+// to a structure passed to ReturningAll or ReturningStruct.
+func (q *Stmt) queryStructRow(ctx context.Context, db Executor, query string) error {
+	rows, err := db.QueryContext(ctx, query, q.args...)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	if !rows.Next() {
+		if err = rows.Err(); err == nil {
+			err = sql.ErrNoRows
+		}
+		return err
+	}
+	dest, err := q.namedDest(rows)
+	if err != nil {
+		return err
+	}
+	store := func() {}
+	if q.nullAsZero {
+		dest, store = nullable(dest)
+	}
+	if err = rows.Scan(dest...); err != nil {
+		return err
+	}
+	store()
+	return rows.Close()
 }
 
 // QueryRowAndClose executes the statement via Executor methods
@@ -90,12 +384,29 @@ func (q *Stmt) QueryRowAndClose(ctx context.Context, db Executor) error {
 	return err
 }
 
+// GetOrNotFound works like QueryRow, but returns ErrNotFound
+// instead of sql.ErrNoRows if no rows were found.
+func (q *Stmt) GetOrNotFound(ctx context.Context, db Executor) error {
+	err := q.QueryRow(ctx, db)
+	if errors.Is(err, sql.ErrNoRows) {
+		return ErrNotFound
+	}
+	return err
+}
+
 // Exec executes the statement.
 func (q *Stmt) Exec(ctx context.Context, db Executor) (sql.Result, error) {
+	if err := q.check(); err != nil {
+		return nil, err
+	}
+	if isDryRun() {
+		return dryRunResult{}, nil
+	}
 	if ctx == nil {
 		ctx = context.Background()
 	}
-	return db.ExecContext(ctx, q.String(), q.args...)
+	query := q.String()
+	return db.ExecContext(ctx, query, q.args...)
 }
 
 // ExecAndClose executes the statement and releases all the objects
@@ -107,3 +418,63 @@ func (q *Stmt) ExecAndClose(ctx context.Context, db Executor) (sql.Result, error
 	q.Close()
 	return res, err
 }
+
+// ExecQuery executes a statement returned by Stmt.Snapshot.
+func ExecQuery(ctx context.Context, db Executor, q Query) (sql.Result, error) {
+	if isDryRun() {
+		return dryRunResult{}, nil
+	}
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	return db.ExecContext(ctx, q.SQL, q.Args...)
+}
+
+// Ping checks a database connection by executing SELECT 1.
+// It returns nil in dry run mode.
+//
+// The query is sent as is, functions set by Dialect.OnBuild
+// don't alter it.
+func Ping(ctx context.Context, db Executor) error {
+	if isDryRun() {
+		return nil
+	}
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	var one int
+	return db.QueryRowContext(ctx, "SELECT 1").Scan(&one)
+}
+
+// QueryWithTimeout works like Query, but cancels the query
+// if it takes longer than timeout.
+func (q *Stmt) QueryWithTimeout(ctx context.Context, db Executor, timeout time.Duration, handler func(rows *sql.Rows)) error {
+	ctx, cancel := withTimeout(ctx, timeout)
+	defer cancel()
+	return q.Query(ctx, db, handler)
+}
+
+// QueryRowWithTimeout works like QueryRow, but cancels the query
+// if it takes longer than timeout.
+func (q *Stmt) QueryRowWithTimeout(ctx context.Context, db Executor, timeout time.Duration) error {
+	ctx, cancel := withTimeout(ctx, timeout)
+	defer cancel()
+	return q.QueryRow(ctx, db)
+}
+
+// ExecWithTimeout works like Exec, but cancels the statement
+// if it takes longer than timeout.
+func (q *Stmt) ExecWithTimeout(ctx context.Context, db Executor, timeout time.Duration) (sql.Result, error) {
+	ctx, cancel := withTimeout(ctx, timeout)
+	defer cancel()
+	return q.Exec(ctx, db)
+}
+
+// withTimeout derives a context with a timeout from ctx,
+// which can be nil.
+func withTimeout(ctx context.Context, timeout time.Duration) (context.Context, context.CancelFunc) {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	return context.WithTimeout(ctx, timeout)
+}
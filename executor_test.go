@@ -3,6 +3,7 @@ package sqlf_test
 import (
 	"context"
 	"database/sql"
+	"errors"
 	"fmt"
 	"log"
 	"os"
@@ -166,6 +167,353 @@ func TestBindNested(t *testing.T) {
 	})
 }
 
+func TestGetOrNotFound(t *testing.T) {
+	forEveryDB(t, func(ctx context.Context, env *dbEnv) {
+		var name string
+		q := env.sqlf.From("users").
+			Select("name").To(&name).
+			Where("id = ?", 1)
+		defer q.Close()
+		err := q.GetOrNotFound(ctx, env.db)
+		require.NoError(t, err, "Failed to execute a query: %v", err)
+		require.Equal(t, "User 1", name)
+
+		q.Where("id = ?", 2)
+		err = q.GetOrNotFound(ctx, env.db)
+		require.Equal(t, sqlf.ErrNotFound, err)
+		require.True(t, errors.Is(err, sql.ErrNoRows))
+	})
+}
+
+func TestQueryScalars(t *testing.T) {
+	forEveryDB(t, func(ctx context.Context, env *dbEnv) {
+		var names []string
+		q := env.sqlf.From("users").
+			Select("name").
+			Where("id > ?", 1).
+			OrderBy("id")
+		defer q.Close()
+		err := q.QueryScalars(ctx, env.db, &names)
+		require.NoError(t, err, "Failed to execute a query: %v", err)
+		require.Equal(t, []string{"User 2", "User 3"}, names)
+
+		var ids []int64
+		err = q.Select("id").QueryScalars(ctx, env.db, &ids)
+		require.EqualError(t, err, "sqlf: QueryScalars expects a single column, got 2")
+
+		err = q.QueryScalars(ctx, env.db, ids)
+		require.Error(t, err)
+	})
+}
+
+func TestInsertIgnore(t *testing.T) {
+	forEveryDB(t, func(ctx context.Context, env *dbEnv) {
+		_, err := env.sqlf.InsertInto("users").
+			Set("id", 1).
+			Set("name", "User 1a").
+			InsertIgnore().
+			ExecAndClose(ctx, env.db)
+		require.NoError(t, err, "Failed to execute a query: %v", err)
+
+		var name string
+		err = env.sqlf.From("users").
+			Select("name").To(&name).
+			Where("id = ?", 1).
+			QueryRowAndClose(ctx, env.db)
+		require.NoError(t, err, "Failed to execute a query: %v", err)
+		require.Equal(t, "User 1", name)
+	})
+}
+
+func TestReplaceInto(t *testing.T) {
+	forEveryDB(t, func(ctx context.Context, env *dbEnv) {
+		_, err := env.sqlf.ReplaceInto("users").
+			Set("id", 1).
+			Set("name", "User 1a").
+			ExecAndClose(ctx, env.db)
+		require.NoError(t, err, "Failed to execute a query: %v", err)
+
+		var name string
+		err = env.sqlf.From("users").
+			Select("name").To(&name).
+			Where("id = ?", 1).
+			QueryRowAndClose(ctx, env.db)
+		require.NoError(t, err, "Failed to execute a query: %v", err)
+		require.Equal(t, "User 1a", name)
+	})
+}
+
+func TestReturningStruct(t *testing.T) {
+	forEveryDB(t, func(ctx context.Context, env *dbEnv) {
+		var u struct {
+			ID   int64  `db:"id"`
+			Name string `db:"name"`
+		}
+		err := env.sqlf.InsertInto("users").
+			Set("id", 4).
+			Set("name", "User 4").
+			ReturningStruct(&u).
+			QueryRowAndClose(ctx, env.db)
+		require.NoError(t, err, "Failed to execute a query: %v", err)
+		require.Equal(t, "User 4", u.Name)
+		require.EqualValues(t, 4, u.ID)
+	})
+}
+
+func TestReturningAll(t *testing.T) {
+	forEveryDB(t, func(ctx context.Context, env *dbEnv) {
+		var u struct {
+			Name string `db:"name"`
+			ID   int64  `db:"id"`
+		}
+		err := env.sqlf.InsertInto("users").
+			Set("id", 4).
+			Set("name", "User 4").
+			ReturningAll(&u).
+			QueryRowAndClose(ctx, env.db)
+		require.NoError(t, err, "Failed to execute a query: %v", err)
+		require.Equal(t, "User 4", u.Name)
+		require.EqualValues(t, 4, u.ID)
+
+		var names []string
+		err = env.sqlf.Update("users").
+			SetExpr("name", "name || ?", "!").
+			Where("id < ?", 3).
+			ReturningAll(&u).
+			QueryAndClose(ctx, env.db, func(rows *sql.Rows) {
+				names = append(names, u.Name)
+			})
+		require.NoError(t, err, "Failed to execute a query: %v", err)
+		require.ElementsMatch(t, []string{"User 1!", "User 2!"}, names)
+
+		// QueryRow scans the first returned row only
+		err = env.sqlf.Update("users").
+			SetExpr("name", "name || ?", "?").
+			Where("id < ?", 3).
+			ReturningAll(&u).
+			QueryRowAndClose(ctx, env.db)
+		require.NoError(t, err, "Failed to execute a query: %v", err)
+		require.Equal(t, "User 1!?", u.Name)
+		require.EqualValues(t, 1, u.ID)
+
+		err = env.sqlf.DeleteFrom("users").
+			Where("id = ?", 42).
+			ReturningAll(&u).
+			QueryRowAndClose(ctx, env.db)
+		require.Equal(t, sql.ErrNoRows, err)
+	})
+}
+
+func TestQueryReturning(t *testing.T) {
+	forEveryDB(t, func(ctx context.Context, env *dbEnv) {
+		type user struct {
+			ID   int64  `db:"id"`
+			Name string `db:"name"`
+		}
+		var users []user
+		err := env.sqlf.Update("users").
+			SetExpr("name", "name || ?", "!").
+			Where("id < ?", 3).
+			Returning("*").
+			QueryReturning(ctx, env.db, &users)
+		require.NoError(t, err, "Failed to execute a query: %v", err)
+		require.ElementsMatch(t, []user{{1, "User 1!"}, {2, "User 2!"}}, users)
+
+		var ptrs []*user
+		err = env.sqlf.DeleteFrom("users").
+			Where("id = ?", 1).
+			Returning("id, name").
+			QueryReturning(ctx, env.db, &ptrs)
+		require.NoError(t, err, "Failed to execute a query: %v", err)
+		require.Equal(t, []*user{{1, "User 1!"}}, ptrs)
+
+		var ids []int64
+		err = env.sqlf.Update("users").
+			Set("name", "User").
+			Returning("id").
+			QueryReturning(ctx, env.db, &ids)
+		require.NoError(t, err, "Failed to execute a query: %v", err)
+		require.ElementsMatch(t, []int64{2, 3}, ids)
+
+		require.Error(t, env.sqlf.From("users").Select("id").QueryReturning(ctx, env.db, ids))
+	})
+}
+
+func TestSkipColumns(t *testing.T) {
+	forEveryDB(t, func(ctx context.Context, env *dbEnv) {
+		var (
+			id   int64
+			name string
+		)
+		err := env.sqlf.From("users").
+			Select("id, name, name").To(&id, nil, &name).
+			Where("id = ?", 1).
+			QueryRowAndClose(ctx, env.db)
+		require.NoError(t, err)
+		require.Equal(t, int64(1), id)
+		require.Equal(t, "User 1", name)
+
+		var ids []int64
+		err = env.sqlf.From("users").
+			Select("name, id").To(nil, &id).
+			OrderBy("id").
+			QueryAndClose(ctx, env.db, func(rows *sql.Rows) {
+				ids = append(ids, id)
+			})
+		require.NoError(t, err)
+		require.Equal(t, []int64{1, 2, 3}, ids)
+	})
+}
+
+func TestWithRecursiveQuery(t *testing.T) {
+	forEveryDB(t, func(ctx context.Context, env *dbEnv) {
+		var sum int
+		err := env.sqlf.WithRecursive("t(n)",
+			env.sqlf.Select("?", 1),
+			env.sqlf.From("t").Select("n + 1").Where("n < ?", 10),
+			true).
+			From("t").
+			Select("SUM(n)").To(&sum).
+			QueryRowAndClose(ctx, env.db)
+		require.NoError(t, err)
+		require.Equal(t, 55, sum)
+	})
+}
+
+func TestNullAsZero(t *testing.T) {
+	forEveryDB(t, func(ctx context.Context, env *dbEnv) {
+		var (
+			name   = "not empty"
+			id     = int64(42)
+			score  = 1.5
+			active = true
+			data   = []byte("x")
+		)
+		err := env.sqlf.Select("NULL").To(&name).
+			Select("NULL").To(&id).
+			Select("NULL").To(&score).
+			Select("NULL").To(&active).
+			Select("NULL").To(&data).
+			NullAsZero().
+			QueryRowAndClose(ctx, env.db)
+		require.NoError(t, err)
+		require.Equal(t, "", name)
+		require.Zero(t, id)
+		require.Zero(t, score)
+		require.False(t, active)
+		require.Nil(t, data)
+
+		var (
+			count int
+			flag  bool
+			text  string
+			blob  []byte
+			ns    sql.NullString
+		)
+		err = env.sqlf.Select("2").To(&count).
+			Select("1").To(&flag).
+			Select("'text'").To(&text).
+			Select("'blob'").To(&blob).
+			Select("'ns'").To(&ns).
+			NullAsZero().
+			QueryRowAndClose(ctx, env.db)
+		require.NoError(t, err)
+		require.Equal(t, 2, count)
+		require.True(t, flag)
+		require.Equal(t, "text", text)
+		require.Equal(t, []byte("blob"), blob)
+		require.Equal(t, sql.NullString{String: "ns", Valid: true}, ns)
+
+		var u struct {
+			ID   int64  `db:"id"`
+			Name string `db:"name"`
+		}
+		var names []string
+		err = env.sqlf.From("users").
+			Select("id").To(&u.ID).
+			Select("NULL").To(&u.Name).
+			NullAsZero().
+			QueryAndClose(ctx, env.db, func(rows *sql.Rows) {
+				names = append(names, u.Name)
+			})
+		require.NoError(t, err)
+		require.NotEmpty(t, names)
+		require.Empty(t, names[0])
+
+		err = env.sqlf.Select("NULL").To(&name).QueryRowAndClose(ctx, env.db)
+		require.Error(t, err)
+
+		// Values are converted the way Scan does it
+		var small int8
+		err = env.sqlf.Select("300").To(&small).NullAsZero().QueryRowAndClose(ctx, env.db)
+		require.Error(t, err)
+		err = env.sqlf.Select("1.7").To(&count).NullAsZero().QueryRowAndClose(ctx, env.db)
+		require.Error(t, err)
+		err = env.sqlf.From("users").Select("1.7").To(&count).NullAsZero().QueryAndClose(ctx, env.db, func(rows *sql.Rows) {})
+		require.Error(t, err)
+	})
+}
+
+func TestQueryCancel(t *testing.T) {
+	forEveryDB(t, func(ctx context.Context, env *dbEnv) {
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+
+		var (
+			id   int64
+			seen int
+		)
+		err := env.sqlf.From("users").
+			Select("id").To(&id).
+			QueryAndClose(ctx, env.db, func(rows *sql.Rows) {
+				seen++
+				cancel()
+			})
+		require.Equal(t, context.Canceled, err)
+		require.Equal(t, 1, seen)
+
+		// The connection is released and can be reused
+		err = env.sqlf.From("users").Select("COUNT(*)").To(&id).QueryRowAndClose(context.Background(), env.db)
+		require.NoError(t, err)
+	})
+}
+
+func TestPing(t *testing.T) {
+	forEveryDB(t, func(ctx context.Context, env *dbEnv) {
+		require.NoError(t, sqlf.Ping(ctx, env.db))
+	})
+
+	db, err := sql.Open("sqlite3", ":memory:")
+	require.NoError(t, err)
+	require.NoError(t, db.Close())
+	require.Error(t, sqlf.Ping(context.Background(), db))
+
+	// OnBuild functions don't alter the query
+	called := false
+	sqlf.DefaultDialect().OnBuild(func(q *sqlf.Stmt) {
+		called = true
+		q.Where("no_such_column = 1")
+	})
+	defer sqlf.DefaultDialect().OnBuild(nil)
+	forEveryDB(t, func(ctx context.Context, env *dbEnv) {
+		require.NoError(t, sqlf.Ping(ctx, env.db))
+	})
+	require.False(t, called)
+}
+
+func TestIndexedPlaceholdersExec(t *testing.T) {
+	forEveryDB(t, func(ctx context.Context, env *dbEnv) {
+		var count int
+		q := sqlf.SQLite.From("users").
+			Select("COUNT(*)").To(&count).
+			Where("id >= ?1 AND id <= ?1 + ?", 1, 1)
+		defer q.Close()
+		require.Equal(t, []interface{}{1, 1}, q.Args())
+		require.NoError(t, q.QueryRow(ctx, env.db))
+		require.Equal(t, 2, count)
+	})
+}
+
 func TestExec(t *testing.T) {
 	forEveryDB(t, func(ctx context.Context, env *dbEnv) {
 		var (
@@ -306,6 +654,115 @@ func TestQueryAndClose(t *testing.T) {
 	})
 }
 
+// recordingDB is an Executor that remembers the last executed statement.
+type recordingDB struct {
+	query string
+	args  []interface{}
+}
+
+func (db *recordingDB) ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error) {
+	db.query, db.args = query, append([]interface{}(nil), args...)
+	return nil, nil
+}
+
+func (db *recordingDB) QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error) {
+	db.query, db.args = query, append([]interface{}(nil), args...)
+	return nil, sql.ErrConnDone
+}
+
+func (db *recordingDB) QueryRowContext(ctx context.Context, query string, args ...interface{}) *sql.Row {
+	db.query, db.args = query, append([]interface{}(nil), args...)
+	return nil
+}
+
+type contextDB struct {
+	recordingDB
+	ctx context.Context
+}
+
+func (db *contextDB) ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error) {
+	db.ctx = ctx
+	return db.recordingDB.ExecContext(ctx, query, args...)
+}
+
+func (db *contextDB) QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error) {
+	db.ctx = ctx
+	return db.recordingDB.QueryContext(ctx, query, args...)
+}
+
+func TestWithTimeout(t *testing.T) {
+	db := new(contextDB)
+	_, err := sqlf.DeleteFrom("users").ExecWithTimeout(nil, db, time.Minute)
+	require.NoError(t, err)
+	deadline, ok := db.ctx.Deadline()
+	require.True(t, ok)
+	require.WithinDuration(t, time.Now().Add(time.Minute), deadline, 10*time.Second)
+	// The context is cancelled as soon as the statement is executed
+	require.Equal(t, context.Canceled, db.ctx.Err())
+
+	err = sqlf.From("users").Select("id").QueryWithTimeout(ctx, db, time.Minute, func(rows *sql.Rows) {})
+	require.Equal(t, sql.ErrConnDone, err)
+	require.Equal(t, context.Canceled, db.ctx.Err())
+}
+
+func TestSnapshot(t *testing.T) {
+	data := []byte("data")
+	q := sqlf.PostgreSQL.Update("users").Set("blob", data).Where("id = ?", 42)
+	snapshot := q.Snapshot()
+	q.Close()
+	data[0] = 'D'
+
+	require.Equal(t, sqlf.Query{
+		SQL:  "UPDATE users SET blob=$1 WHERE id = $2",
+		Args: []interface{}{[]byte("data"), 42},
+	}, snapshot)
+
+	db := new(recordingDB)
+	_, err := sqlf.ExecQuery(nil, db, snapshot)
+	require.NoError(t, err)
+	require.Equal(t, snapshot.SQL, db.query)
+	require.Equal(t, snapshot.Args, db.args)
+}
+
+func TestNamedArgs(t *testing.T) {
+	db := new(recordingDB)
+	_, err := sqlf.Update("users").
+		SetExpr("name", "@name", sql.Named("name", "User")).
+		Where("id = @id", sql.Named("id", 42)).
+		ExecAndClose(nil, db)
+	require.NoError(t, err)
+	require.Equal(t, "UPDATE users SET name=@name WHERE id = @id", db.query)
+	require.Equal(t, []interface{}{sql.Named("name", "User"), sql.Named("id", 42)}, db.args)
+}
+
+func TestDryRun(t *testing.T) {
+	sqlf.SetDryRun(true)
+	defer sqlf.SetDryRun(false)
+
+	db := new(recordingDB)
+	res, err := sqlf.DeleteFrom("users").Where("id = ?", 1).ExecAndClose(ctx, db)
+	require.NoError(t, err)
+	n, err := res.RowsAffected()
+	require.NoError(t, err)
+	require.Zero(t, n)
+
+	var name string
+	err = sqlf.From("users").Select("name").To(&name).QueryRowAndClose(ctx, db)
+	require.Equal(t, sql.ErrNoRows, err)
+
+	called := false
+	err = sqlf.From("users").Select("name").To(&name).QueryAndClose(ctx, db, func(rows *sql.Rows) {
+		called = true
+	})
+	require.NoError(t, err)
+	require.False(t, called)
+
+	var b sqlf.Batch
+	require.NoError(t, b.Add(sqlf.DeleteFrom("users")).ExecBatch(ctx, db))
+
+	require.Empty(t, db.query)
+}
+
 var sqlSchemaCreate = []string{
 	`CREATE TABLE users (
 		id int IDENTITY PRIMARY KEY,
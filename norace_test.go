@@ -0,0 +1,5 @@
+//go:build !race
+
+package sqlf_test
+
+const raceEnabled = false
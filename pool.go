@@ -37,6 +37,10 @@ func reuseStmt(q *Stmt) {
 		}
 		q.dest = q.dest[:0]
 	}
+	q.destStruct = nil
+	q.nullAsZero = false
+	q.hooked = false
+	q.err = nil
 	putBuffer(q.buf)
 	q.buf = nil
 	q.sql = ""
@@ -0,0 +1,7 @@
+//go:build race
+
+package sqlf_test
+
+// raceEnabled is true if tests are run with the race detector,
+// which makes sync.Pool drop pooled objects at random.
+const raceEnabled = true
@@ -0,0 +1,64 @@
+package sqlf
+
+import (
+	"database/sql"
+	"reflect"
+)
+
+// discardNil replaces nil scan targets with sinks discarding column values.
+// dest is returned as is if it has no nil targets.
+func discardNil(dest []interface{}) []interface{} {
+	for i, d := range dest {
+		if d == nil {
+			sinks := make([]interface{}, len(dest))
+			copy(sinks, dest)
+			for ; i < len(sinks); i++ {
+				if sinks[i] == nil {
+					sinks[i] = new(interface{})
+				}
+			}
+			return sinks
+		}
+	}
+	return dest
+}
+
+// nullable returns scan targets accepting NULLs for dest
+// and a function storing scanned values to dest.
+//
+// Values are scanned to pointers to dest types, so database/sql
+// converts them the way it does for dest itself. The function
+// stores a zero value instead of a NULL one.
+func nullable(dest []interface{}) ([]interface{}, func()) {
+	var targets []nullTarget
+	wrapped := make([]interface{}, len(dest))
+	for i, d := range dest {
+		wrapped[i] = d
+		if _, ok := d.(sql.Scanner); ok {
+			continue
+		}
+		v := reflect.ValueOf(d)
+		if v.Kind() != reflect.Ptr || v.IsNil() {
+			// Let Scan report an invalid target
+			continue
+		}
+		ptr := reflect.New(v.Type())
+		wrapped[i] = ptr.Interface()
+		targets = append(targets, nullTarget{dest: v.Elem(), ptr: ptr.Elem()})
+	}
+	return wrapped, func() {
+		for _, t := range targets {
+			if t.ptr.IsNil() {
+				t.dest.Set(reflect.Zero(t.dest.Type()))
+			} else {
+				t.dest.Set(t.ptr.Elem())
+			}
+		}
+	}
+}
+
+// nullTarget is a scan target and a pointer a value is scanned to.
+type nullTarget struct {
+	dest reflect.Value
+	ptr  reflect.Value
+}
@@ -0,0 +1,16 @@
+module github.com/leporo/sqlf/sqlxf
+
+go 1.13
+
+require (
+	github.com/jmoiron/sqlx v1.3.5
+	// The first sqlf revision with Stmt.Err
+	github.com/leporo/sqlf v0.0.0-20261015183911-4cfd72f6dddb
+	github.com/mattn/go-sqlite3 v1.14.16
+	github.com/stretchr/testify v1.8.2
+)
+
+// Build against the sqlf source of this repository during local development
+// and in CI. Go ignores replace directives of dependencies, so sqlxf users
+// get the sqlf version required above or a newer one.
+replace github.com/leporo/sqlf => ../
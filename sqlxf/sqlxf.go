@@ -0,0 +1,55 @@
+/*
+Package sqlxf executes sqlf statements with sqlx scanning.
+
+Build a statement with sqlf and let sqlx map the result to structures:
+
+	var users []User
+	q := sqlf.From("users").
+		Select("id, name").
+		Where("is_active = ?", true)
+	err := sqlxf.QueryxAll(ctx, db, q, &users)
+	q.Close()
+
+sqlxf is a separate module, so sqlf doesn't depend on sqlx
+unless sqlxf is imported.
+*/
+package sqlxf
+
+import (
+	"context"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/leporo/sqlf"
+)
+
+// QueryxAll executes a statement and scans all the returned rows into dest
+// using sqlx.SelectContext.
+//
+// Scan targets bound via To and Bind methods of a statement are ignored.
+// An error recorded by statement builder methods is returned
+// without running a statement, see sqlf.Stmt.Err.
+func QueryxAll(ctx context.Context, db sqlx.QueryerContext, q *sqlf.Stmt, dest interface{}) error {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	if err := q.Err(); err != nil {
+		return err
+	}
+	return sqlx.SelectContext(ctx, db, dest, q.String(), q.Args()...)
+}
+
+// QueryxRow executes a statement and scans a single returned row into dest
+// using sqlx.GetContext.
+//
+// Scan targets bound via To and Bind methods of a statement are ignored.
+// An error recorded by statement builder methods is returned
+// without running a statement, see sqlf.Stmt.Err.
+func QueryxRow(ctx context.Context, db sqlx.QueryerContext, q *sqlf.Stmt, dest interface{}) error {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	if err := q.Err(); err != nil {
+		return err
+	}
+	return sqlx.GetContext(ctx, db, dest, q.String(), q.Args()...)
+}
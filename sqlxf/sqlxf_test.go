@@ -0,0 +1,55 @@
+package sqlxf_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/leporo/sqlf"
+	"github.com/leporo/sqlf/sqlxf"
+	_ "github.com/mattn/go-sqlite3"
+	"github.com/stretchr/testify/require"
+)
+
+type user struct {
+	ID   int64  `db:"id"`
+	Name string `db:"name"`
+}
+
+func TestQueryx(t *testing.T) {
+	db, err := sqlx.Open("sqlite3", ":memory:")
+	require.NoError(t, err)
+	defer db.Close()
+	db.SetMaxOpenConns(1)
+	db.MustExec(`CREATE TABLE users (id int PRIMARY KEY, name varchar(128) NOT NULL)`)
+	db.MustExec(`INSERT INTO users (id, name) VALUES (1, "User 1"), (2, "User 2"), (3, "User 3")`)
+
+	ctx := context.Background()
+
+	var users []user
+	q := sqlf.NoDialect.From("users").
+		Select("id, name").
+		Where("id > ?", 1).
+		OrderBy("id")
+	err = sqlxf.QueryxAll(ctx, db, q, &users)
+	q.Close()
+	require.NoError(t, err)
+	require.Equal(t, []user{{2, "User 2"}, {3, "User 3"}}, users)
+
+	var u user
+	q = sqlf.NoDialect.From("users").
+		Select("id, name").
+		Where("id = ?", 1)
+	err = sqlxf.QueryxRow(nil, db, q, &u)
+	q.Close()
+	require.NoError(t, err)
+	require.Equal(t, user{1, "User 1"}, u)
+
+	// Statements with builder errors are not executed
+	q = sqlf.NoDialect.From("users").
+		Select("id, name").
+		WhereTupleIn([]string{"id", "name"}, [][]interface{}{{1}})
+	require.Error(t, sqlxf.QueryxAll(ctx, db, q, &users))
+	require.Equal(t, q.Err(), sqlxf.QueryxRow(ctx, db, q, &u))
+	q.Close()
+}
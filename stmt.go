@@ -1,7 +1,13 @@
 package sqlf
 
 import (
+	"bytes"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"io"
 	"reflect"
+	"sort"
 	"strings"
 
 	"github.com/valyala/bytebufferpool"
@@ -54,6 +60,15 @@ func With(queryName string, query *Stmt) *Stmt {
 	return defaultDialect.With(queryName, query)
 }
 
+/*
+WithRecursive starts a statement prepended by a recursive WITH clause
+and closes subqueries passed as arguments.
+See Stmt.WithRecursive for details.
+*/
+func WithRecursive(queryName string, base, recursive *Stmt, all bool) *Stmt {
+	return defaultDialect.WithRecursive(queryName, base, recursive, all)
+}
+
 /*
 Select starts a SELECT statement.
 
@@ -104,6 +119,18 @@ func InsertInto(tableName string) *Stmt {
 	return defaultDialect.InsertInto(tableName)
 }
 
+/*
+ReplaceInto starts a REPLACE statement supported by MySQL and SQLite.
+
+	err := sqlf.ReplaceInto("table").
+		Set("id", 42).
+		Set("field", value).
+		ExecAndClose(ctx, db)
+*/
+func ReplaceInto(tableName string) *Stmt {
+	return defaultDialect.ReplaceInto(tableName)
+}
+
 /*
 DeleteFrom starts a DELETE statement.
 
@@ -113,12 +140,34 @@ func DeleteFrom(tableName string) *Stmt {
 	return defaultDialect.DeleteFrom(tableName)
 }
 
+/*
+Values builds a VALUES list to be used as a subquery.
+
+	q := sqlf.From("").
+		SubQuery("(", ") AS t(id, name)",
+			sqlf.Values([]string{"id", "name"}, [][]interface{}{
+				{1, "a"},
+				{2, "b"},
+			})).
+		Select("t.id, t.name")
+
+produces
+
+	SELECT t.id, t.name FROM (VALUES (?, ?), (?, ?)) AS t(id, name)
+
+Arguments are ordered row by row.
+*/
+func Values(columns []string, rows [][]interface{}) *Stmt {
+	return defaultDialect.Values(columns, rows)
+}
+
 type stmtChunk struct {
 	pos     chunkPos
 	bufLow  int
 	bufHigh int
 	hasExpr bool
 	argLen  int
+	escaped bool
 }
 type stmtChunks []stmtChunk
 
@@ -145,13 +194,17 @@ For other SQL statements use New:
 	}
 */
 type Stmt struct {
-	dialect *Dialect
-	pos     chunkPos
-	chunks  stmtChunks
-	buf     *bytebufferpool.ByteBuffer
-	sql     string
-	args    []interface{}
-	dest    []interface{}
+	dialect    *Dialect
+	pos        chunkPos
+	chunks     stmtChunks
+	buf        *bytebufferpool.ByteBuffer
+	sql        string
+	args       []interface{}
+	dest       []interface{}
+	destStruct interface{}
+	nullAsZero bool
+	hooked     bool
+	err        error
 }
 
 type newRow struct {
@@ -193,10 +246,122 @@ Use To method to bind variables to selected columns:
 Note that a SELECT statement can also be started by a From method call.
 */
 func (q *Stmt) Select(expr string, args ...interface{}) *Stmt {
+	expr, args = q.indexArgs(expr, args)
 	q.addChunk(posSelect, "SELECT", expr, args, ", ")
 	return q
 }
 
+/*
+SelectAt inserts an expression into a SELECT clause at a given
+position instead of appending it:
+
+	q := sqlf.From("users").
+		Select("name, email").
+		SelectAt(0, "id")
+
+produces
+
+	SELECT id, name, email FROM users
+
+Expressions are counted the way SelectColumnCount does it.
+If index is out of range, the expression is appended to the end of the list.
+
+Scan targets bound via To method calls are not reordered.
+*/
+func (q *Stmt) SelectAt(index int, expr string, args ...interface{}) *Stmt {
+	expr, args = q.indexArgs(expr, args)
+	verb := "SELECT"
+	text := strings.TrimPrefix(q.chunkText(posSelect), verb)
+	if strings.HasPrefix(text, " DISTINCT ") {
+		verb += " DISTINCT"
+		text = text[len(" DISTINCT"):]
+	}
+	items := splitList(text)
+	if index < 0 {
+		index = 0
+	}
+	if index >= len(items) {
+		return q.Select(expr, args...)
+	}
+
+	selectArgs, escaped := q.chunkArgs(posSelect)
+	argNo := 0
+	for _, item := range items[:index] {
+		argNo += countPlaceholders(item)
+	}
+	selectArgs = insertAt(selectArgs, args, argNo)
+
+	items = append(items, "")
+	copy(items[index+1:], items[index:])
+	items[index] = expr
+
+	q.removeChunks(posSelect, posSelect)
+	i := q.addChunk(posSelect, verb, strings.Join(items, ", "), selectArgs, ", ")
+	q.chunks[i].escaped = escaped
+	return q
+}
+
+/*
+Distinct makes a SELECT statement return distinct rows only:
+
+	q := sqlf.From("orders").
+		Bind(&order).
+		Distinct()
+
+produces
+
+	SELECT DISTINCT id, user_id, amount FROM orders
+
+Distinct can be called before or after columns are added.
+*/
+func (q *Stmt) Distinct() *Stmt {
+	text := q.chunkText(posSelect)
+	switch {
+	case text == "":
+		q.addChunk(posSelect, "SELECT DISTINCT", "", nil, ", ")
+	case !strings.HasPrefix(text, "SELECT DISTINCT"):
+		q.replaceVerb(posSelect, "SELECT", "SELECT DISTINCT")
+	}
+	return q
+}
+
+/*
+SelectColumns adds columns chosen by a user to a SELECT clause,
+making sure every column is listed in allowed:
+
+	allowed := map[string]bool{"id": true, "name": true, "email": true}
+	if err := q.SelectColumns(allowed, r.URL.Query()["column"]); err != nil {
+		return err
+	}
+
+SelectColumns returns an error and leaves the statement intact if any of
+requested columns is not allowed.
+*/
+func (q *Stmt) SelectColumns(allowed map[string]bool, requested []string) error {
+	for _, column := range requested {
+		if !allowed[column] {
+			return fmt.Errorf("sqlf: column %q is not allowed", column)
+		}
+	}
+	if len(requested) > 0 {
+		q.Select(strings.Join(requested, ", "))
+	}
+	return nil
+}
+
+/*
+SelectAs adds an aliased expression to a SELECT clause:
+
+	q.SelectAs("SUM(amount)", "total").To(&total)
+
+produces
+
+	SELECT SUM(amount) AS total
+*/
+func (q *Stmt) SelectAs(expr, alias string, args ...interface{}) *Stmt {
+	return q.Select(expr+" AS "+alias, args...)
+}
+
 /*
 To sets a scan target for columns to be selected.
 
@@ -216,6 +381,11 @@ Query and QueryRow methods.
 		// ...
 	}
 
+Pass nil instead of a pointer to skip a column:
+
+	q := sqlf.From("table").
+		Select("field1, field2, field3").To(&field1, nil, &field3)
+
 To method MUST be called immediately after Select, Returning or other
 method that defines data to be returned.
 */
@@ -254,7 +424,24 @@ tableName argument can be a SQL fragment:
 	q.InsertInto("table AS t")
 */
 func (q *Stmt) InsertInto(tableName string) *Stmt {
-	q.addChunk(posInsert, "INSERT INTO", tableName, nil, ", ")
+	return q.insert("INSERT INTO", tableName)
+}
+
+/*
+ReplaceInto adds REPLACE INTO clause to a statement.
+
+REPLACE is supported by MySQL and SQLite. It works the same way
+as INSERT, but deletes existing rows conflicting with the new ones first.
+
+	q.ReplaceInto("table").Set("id", 42).Set("name", name)
+*/
+func (q *Stmt) ReplaceInto(tableName string) *Stmt {
+	return q.insert("REPLACE INTO", tableName)
+}
+
+// insert adds INSERT-like clause to a statement.
+func (q *Stmt) insert(verb, tableName string) *Stmt {
+	q.addChunk(posInsert, verb, tableName, nil, ", ")
 	q.addChunk(posInsertFields-1, "(", "", nil, "")
 	q.addChunk(posValues-1, ") VALUES (", "", nil, "")
 	q.addChunk(posValues+1, ")", "", nil, "")
@@ -262,10 +449,157 @@ func (q *Stmt) InsertInto(tableName string) *Stmt {
 	return q
 }
 
+/*
+InsertIgnore makes an INSERT statement skip rows conflicting
+with existing ones:
+
+	q := sqlf.MySQL.InsertInto("users").
+		Set("email", email).
+		InsertIgnore()
+
+MySQL dialect produces
+
+	INSERT IGNORE INTO users ( email ) VALUES ( ? )
+
+SQLite dialect produces
+
+	INSERT OR IGNORE INTO users ( email ) VALUES ( ? )
+
+Other dialects add an ON CONFLICT DO NOTHING clause
+supported by PostgreSQL and SQLite:
+
+	INSERT INTO users ( email ) VALUES ( ? ) ON CONFLICT DO NOTHING
+
+InsertIgnore must be called after InsertInto.
+*/
+func (q *Stmt) InsertIgnore() *Stmt {
+	switch q.dialect {
+	case MySQL:
+		q.replaceVerb(posInsert, "INSERT INTO", "INSERT IGNORE INTO")
+	case SQLite:
+		q.replaceVerb(posInsert, "INSERT INTO", "INSERT OR IGNORE INTO")
+	default:
+		q.addChunk(posValues+2, "ON CONFLICT DO NOTHING", "", nil, "")
+	}
+	return q
+}
+
+/*
+Upsert makes an INSERT statement update an existing row
+on a unique key conflict:
+
+	q := sqlf.PostgreSQL.InsertInto("counters").
+		Set("id", id).
+		Set("value", 1).
+		Upsert([]string{"id"}, []string{"value"})
+
+PostgreSQL dialect produces
+
+	INSERT INTO counters ( id, value ) VALUES ( $1, $2 ) ON CONFLICT (id) DO UPDATE SET value = EXCLUDED.value
+
+SQLite dialect produces the same ON CONFLICT clause.
+MySQL dialect ignores conflictColumns and produces
+
+	INSERT INTO counters ( id, value ) VALUES ( ?, ? ) ON DUPLICATE KEY UPDATE value = VALUES(value)
+
+If updateColumns is empty, conflicting rows are left intact
+the way InsertIgnore does it.
+
+PostgreSQL requires a conflict target to update conflicting rows,
+so Upsert records an error if conflictColumns is empty while
+updateColumns is not for dialects other than MySQL and SQLite, see Err.
+SQLite 3.35+ accepts ON CONFLICT DO UPDATE with no conflict target.
+
+Upsert must be called after InsertInto.
+*/
+func (q *Stmt) Upsert(conflictColumns []string, updateColumns []string) *Stmt {
+	if len(updateColumns) == 0 {
+		if q.dialect == MySQL {
+			return q.InsertIgnore()
+		}
+		return q.onConflict(conflictColumns, "DO NOTHING")
+	}
+
+	if q.dialect == MySQL {
+		set := make([]string, len(updateColumns))
+		for i, col := range updateColumns {
+			set[i] = col + " = VALUES(" + col + ")"
+		}
+		q.addChunk(posValues+2, "ON DUPLICATE KEY UPDATE "+strings.Join(set, ", "), "", nil, "")
+		return q
+	}
+
+	excluded := "EXCLUDED."
+	if q.dialect == SQLite {
+		excluded = "excluded."
+	} else if len(conflictColumns) == 0 {
+		q.setErr(errors.New("sqlf: Upsert is called with no conflict columns to update"))
+		return q
+	}
+	set := make([]string, len(updateColumns))
+	for i, col := range updateColumns {
+		set[i] = col + " = " + excluded + col
+	}
+	return q.onConflict(conflictColumns, "DO UPDATE SET "+strings.Join(set, ", "))
+}
+
+// onConflict adds an ON CONFLICT clause with a given action.
+func (q *Stmt) onConflict(columns []string, action string) *Stmt {
+	clause := "ON CONFLICT "
+	if len(columns) > 0 {
+		clause += "(" + strings.Join(columns, ", ") + ") "
+	}
+	q.addChunk(posValues+2, clause+action, "", nil, "")
+	return q
+}
+
+/*
+InsertSelect makes an INSERT statement insert rows returned by a query.
+
+	q := sqlf.InsertInto("archive").
+		InsertSelect([]string{"a", "b"},
+			sqlf.From("live").
+				Select("a, b").
+				Where("ts < ?", ts))
+
+produces
+
+	INSERT INTO archive ( a, b ) SELECT a, b FROM live WHERE ts < ?
+
+The column list is omitted if columns is empty.
+
+InsertSelect method call closes the Stmt passed as source parameter.
+Do not reuse it afterwards.
+*/
+func (q *Stmt) InsertSelect(columns []string, source *Stmt) *Stmt {
+	// Remove the column list and VALUES clause added by InsertInto
+	q.removeChunks(posInsertFields-1, posValues+1)
+	if len(columns) > 0 {
+		q.addChunk(posInsertFields-1, "(", "", nil, "")
+		q.addChunk(posInsertFields, "", strings.Join(columns, ", "), nil, ", ")
+		q.addChunk(posValues-1, ")", "", nil, "")
+	}
+	index := q.addChunk(posValues, "", "", source.args, "")
+	chunk := &q.chunks[index]
+	q.writeSubQuery(index, source)
+	chunk.bufHigh = q.buf.Len()
+	return q
+}
+
 /*
 DeleteFrom adds DELETE clause to a statement.
 
 	q.DeleteFrom("table").Where("id = ?", id)
+
+MySQL dialect turns a statement with joins into a multi-table DELETE:
+
+	sqlf.MySQL.DeleteFrom("orders o").
+		Join("users u", "u.id = o.user_id").
+		Where("u.is_deleted")
+
+produces
+
+	DELETE o FROM orders o JOIN users u ON (u.id = o.user_id) WHERE u.is_deleted
 */
 func (q *Stmt) DeleteFrom(tableName string) *Stmt {
 	q.addChunk(posDelete, "DELETE FROM", tableName, nil, ", ")
@@ -299,13 +633,89 @@ func (q *Stmt) Set(field string, value interface{}) *Stmt {
 	return q.SetExpr(field, "?", value)
 }
 
+/*
+SetIfValid calls Set if value is not NULL.
+
+Use it to update columns with optional values like sql.NullString:
+
+	q := sqlf.Update("users").
+		SetIfValid("name", form.Name).
+		SetIfValid("email", form.Email).
+		Where("id = ?", id)
+
+value is considered NULL if it's nil, a nil pointer or its Value
+method returns nil without an error. Other values, including the ones
+failing to convert, are passed to a database driver as is.
+*/
+func (q *Stmt) SetIfValid(field string, value driver.Valuer) *Stmt {
+	if value == nil {
+		return q
+	}
+	if v := reflect.ValueOf(value); v.Kind() == reflect.Ptr && v.IsNil() {
+		return q
+	}
+	if v, err := value.Value(); v == nil && err == nil {
+		return q
+	}
+	return q.Set(field, value)
+}
+
+/*
+SetJSONB sets a value of a PostgreSQL JSONB column at a given path:
+
+	q := sqlf.PostgreSQL.Update("users").
+		SetJSONB("profile", "address.city", "Paris")
+
+produces
+
+	UPDATE users SET profile=jsonb_set(profile, $1::text[], $2::jsonb)
+
+Path elements are separated with dots. value is encoded to JSON
+when a statement is executed, encoding errors are reported
+by a database driver.
+*/
+func (q *Stmt) SetJSONB(column, path string, value interface{}) *Stmt {
+	return q.SetExpr(column, "jsonb_set("+column+", ?::text[], ?::jsonb)", jsonbPath(path), jsonValue{value})
+}
+
+/*
+SetJSONBRemove removes a key or an array element at a given path
+from a PostgreSQL JSONB column:
+
+	q.SetJSONBRemove("profile", "address.city")
+
+produces
+
+	SET profile=profile #- $1::text[]
+*/
+func (q *Stmt) SetJSONBRemove(column, path string) *Stmt {
+	return q.SetExpr(column, column+" #- ?::text[]", jsonbPath(path))
+}
+
+/*
+SetJSONBMerge merges a JSON-encoded value into a PostgreSQL JSONB column:
+
+	q.SetJSONBMerge("settings", map[string]interface{}{"theme": "dark"})
+
+produces
+
+	SET settings=settings || $1::jsonb
+*/
+func (q *Stmt) SetJSONBMerge(column string, value interface{}) *Stmt {
+	return q.SetExpr(column, column+" || ?::jsonb", jsonValue{value})
+}
+
 /*
 SetExpr is an extended version of Set method.
 
 	q.SetExpr("field", "field + 1")
 	q.SetExpr("field", "? + ?", 31, 11)
+
+SetExpr records an error if a statement has no INSERT or UPDATE clause yet,
+see Err.
 */
 func (q *Stmt) SetExpr(field, expr string, args ...interface{}) *Stmt {
+	expr, args = q.indexArgs(expr, args)
 	p := chunkPos(0)
 	for _, chunk := range q.chunks {
 		if chunk.pos == posInsert || chunk.pos == posUpdate {
@@ -320,12 +730,25 @@ func (q *Stmt) SetExpr(field, expr string, args ...interface{}) *Stmt {
 		q.addChunk(posValues, "", expr, args, ", ")
 	case posUpdate:
 		q.addChunk(posSet, "SET", field+"="+expr, args, ", ")
+	default:
+		q.setErr(errors.New("sqlf: Set is called for a statement with no INSERT or UPDATE clause"))
 	}
 	return q
 }
 
+/*
+SetCond is a version of SetExpr method accepting an expression
+created by Expr function.
+
+	q.SetCond("amount", sqlf.Expr("amount + ?", 10))
+*/
+func (q *Stmt) SetCond(field string, c Cond) *Stmt {
+	return q.SetExpr(field, c.Expr, c.Args...)
+}
+
 // From adds a FROM clause to statement.
 func (q *Stmt) From(expr string, args ...interface{}) *Stmt {
+	expr, args = q.indexArgs(expr, args)
 	q.addChunk(posFrom, "FROM", expr, args, ", ")
 	return q
 }
@@ -339,96 +762,1021 @@ Where adds a filter:
 		Where("is_active = 1")
 */
 func (q *Stmt) Where(expr string, args ...interface{}) *Stmt {
+	expr, args = q.indexArgs(expr, args)
 	q.addChunk(posWhere, "WHERE", expr, args, " AND ")
 	return q
 }
 
 /*
-In adds IN expression to the current filter.
+WhereNot adds a negated filter:
 
-In method must be called after a Where method call.
-*/
-func (q *Stmt) In(args ...interface{}) *Stmt {
-	buf := bytebufferpool.Get()
-	buf.WriteString("IN (")
-	l := len(args) - 1
-	for i := range args {
-		if i < l {
-			buf.Write(placeholderComma)
-		} else {
-			buf.Write(placeholder)
-		}
-	}
-	buf.WriteString(")")
+	q.WhereNot("status = ? OR amount > ?", "new", 100)
 
-	q.addChunk(posWhere, "", bufToString(&buf.B), args, " ")
+produces
 
-	bytebufferpool.Put(buf)
-	return q
+	WHERE NOT (status = ? OR amount > ?)
+*/
+func (q *Stmt) WhereNot(expr string, args ...interface{}) *Stmt {
+	return q.Where("NOT ("+expr+")", args...)
 }
 
 /*
-Join adds an INNERT JOIN clause to SELECT statement
+AndWhereFrom appends filters of another statement to the statement:
+
+	active := sqlf.From("").Where("is_active").Where("region = ?", region)
+	defer active.Close()
+
+	q := sqlf.From("users").Select("id").Where("age > ?", 18).AndWhereFrom(active)
+
+produces
+
+	SELECT id FROM users WHERE age > ? AND (is_active AND region = ?)
+
+other statement is not modified or closed.
 */
-func (q *Stmt) Join(table, on string) *Stmt {
-	q.join("JOIN ", table, on)
+func (q *Stmt) AndWhereFrom(other *Stmt) *Stmt {
+	var (
+		buf     strings.Builder
+		args    []interface{}
+		argNo   int
+		escaped bool
+	)
+	for _, chunk := range other.chunks {
+		if chunk.pos == posWhere {
+			buf.Write(other.buf.B[chunk.bufLow:chunk.bufHigh])
+			args = append(args, other.args[argNo:argNo+chunk.argLen]...)
+			escaped = escaped || chunk.escaped
+		}
+		argNo += chunk.argLen
+	}
+	expr := strings.TrimPrefix(buf.String(), "WHERE ")
+	if expr == "" {
+		return q
+	}
+	if !q.dialect.numbered() && strings.Contains(expr, backRef) {
+		q.setErr(errors.New("sqlf: filters with ?N placeholders are built for another dialect"))
+		return q
+	}
+	index := q.addChunk(posWhere, "WHERE", "("+expr+")", args, " AND ")
+	if escaped {
+		q.chunks[index].escaped = true
+	}
 	return q
 }
 
 /*
-LeftJoin adds a LEFT OUTER JOIN clause to SELECT statement
+WhereRaw adds a filter that has no arguments.
+
+Unlike Where, WhereRaw never treats ? as a placeholder, so PostgreSQL
+operators like ? and ?| are kept as is:
+
+	sqlf.PostgreSQL.From("docs").
+		Select("id").
+		Where("owner_id = ?", ownerID).
+		WhereRaw("tags ? 'urgent'")
+
+produces
+
+	SELECT id FROM docs WHERE owner_id = $1 AND tags ? 'urgent'
+
+Never build expr from user input, pass values as Where arguments instead.
 */
-func (q *Stmt) LeftJoin(table, on string) *Stmt {
-	q.join("LEFT JOIN ", table, on)
+func (q *Stmt) WhereRaw(expr string) *Stmt {
+	// Escape question marks unless a dialect never renumbers placeholders,
+	// the way Comment does it.
+	if q.dialect == MySQL || q.dialect == SQLite || !strings.Contains(expr, "?") {
+		return q.Where(expr)
+	}
+	index := q.addChunk(posWhere, "WHERE", strings.Replace(expr, "?", "\\?", -1), nil, " AND ")
+	q.chunks[index].escaped = true
 	return q
 }
 
 /*
-RightJoin adds a RIGHT OUTER JOIN clause to SELECT statement
+WhereCond adds a filter defined by a condition:
+
+	isNew := sqlf.Expr("status = ?", "new")
+	q.WhereCond(isNew.Or(sqlf.Expr("amount > ?", 100)))
+
+produces
+
+	WHERE (status = ? OR amount > ?)
 */
-func (q *Stmt) RightJoin(table, on string) *Stmt {
-	q.join("RIGHT JOIN ", table, on)
-	return q
+func (q *Stmt) WhereCond(c Cond) *Stmt {
+	return q.Where(c.Expr, c.Args...)
 }
 
 /*
-FullJoin adds a FULL OUTER JOIN clause to SELECT statement
+WhereAll adds a group of conditions joined with AND:
+
+	q.WhereAll(
+		sqlf.Cond{Expr: "status = ?", Args: []interface{}{"new"}},
+		sqlf.Cond{Expr: "amount > ?", Args: []interface{}{100}},
+	)
+
+produces
+
+	WHERE (status = ? AND amount > ?)
+
+WhereAll does nothing if no conditions are passed.
 */
-func (q *Stmt) FullJoin(table, on string) *Stmt {
-	q.join("FULL JOIN ", table, on)
-	return q
+func (q *Stmt) WhereAll(conds ...Cond) *Stmt {
+	if len(conds) == 0 {
+		return q
+	}
+	expr, args := joinConds(" AND ", conds)
+	return q.Where(expr, args...)
 }
 
-// OrderBy adds the ORDER BY clause to SELECT statement
-func (q *Stmt) OrderBy(expr ...string) *Stmt {
-	q.addChunk(posOrderBy, "ORDER BY", strings.Join(expr, ", "), nil, ", ")
-	return q
-}
+/*
+WhereEq adds an equality filter for every column of a map:
 
-// GroupBy adds the GROUP BY clause to SELECT statement
-func (q *Stmt) GroupBy(expr string) *Stmt {
-	q.addChunk(posGroupBy, "GROUP BY", expr, nil, ", ")
+	q.WhereEq(map[string]interface{}{
+		"status":     "new",
+		"deleted_at": nil,
+	})
+
+produces
+
+	WHERE deleted_at IS NULL AND status = ?
+
+Columns are sorted by name, so the same set of columns always
+produces the same statement. Nil values are compared with IS NULL.
+*/
+func (q *Stmt) WhereEq(conditions map[string]interface{}) *Stmt {
+	columns := make([]string, 0, len(conditions))
+	for column := range conditions {
+		columns = append(columns, column)
+	}
+	sort.Strings(columns)
+	for _, column := range columns {
+		if value := conditions[column]; value == nil {
+			q.Where(column + " IS NULL")
+		} else {
+			q.Where(column+" = ?", value)
+		}
+	}
 	return q
 }
 
-// Having adds the HAVING clause to SELECT statement
-func (q *Stmt) Having(expr string, args ...interface{}) *Stmt {
+/*
+In adds IN expression to the current filter.
+
+In method must be called after a Where method call.
+*/
+func (q *Stmt) In(args ...interface{}) *Stmt {
+	buf := bytebufferpool.Get()
+	buf.WriteString("IN (")
+	// Copy placeholders from a precomputed list in as few writes as possible
+	for n := len(args); n > 0; {
+		l := n
+		if l > maxInPlaceholders {
+			l = maxInPlaceholders
+		}
+		n -= l
+		if n > 0 {
+			buf.WriteString(inPlaceholders[:l*2])
+		} else {
+			buf.WriteString(inPlaceholders[:l*2-1])
+		}
+	}
+	buf.WriteString(")")
+
+	q.addChunk(posWhere, "", bufToString(&buf.B), args, " ")
+
+	bytebufferpool.Put(buf)
+	return q
+}
+
+/*
+InAppend adds values to the IN expression added by the preceding
+In or InAppend call:
+
+	q := sqlf.From("users").Select("name").Where("id").In()
+	for ids := range batches {
+		q.InAppend(ids...)
+	}
+
+produces
+
+	SELECT name FROM users WHERE id IN (?,?,?,?)
+
+Note that an empty IN list is not valid SQL, so make sure to add
+at least one value before executing a statement.
+
+InAppend records an error if the last filter of a statement doesn't
+end with an IN expression, see Err.
+*/
+func (q *Stmt) InAppend(args ...interface{}) *Stmt {
+	index := -1
+	for i := len(q.chunks) - 1; i >= 0; i-- {
+		if q.chunks[i].pos == posWhere {
+			index = i
+			break
+		}
+	}
+	tail := -1
+	if index >= 0 {
+		tail = inListTail(q.buf.B[q.chunks[index].bufLow:q.chunks[index].bufHigh])
+	}
+	if tail < 0 {
+		q.setErr(errors.New("sqlf: InAppend is called with no preceding In call"))
+		return q
+	}
+	if len(args) == 0 {
+		return q
+	}
+
+	// Insert placeholders right before a closing parenthesis
+	chunk := &q.chunks[index]
+	placeholders := strings.Repeat(",?", len(args))
+	if tail == 0 {
+		placeholders = placeholders[1:]
+	}
+	p := chunk.bufHigh - 1
+	rest := append([]byte(nil), q.buf.B[p:]...)
+	q.buf.B = append(append(q.buf.B[:p], placeholders...), rest...)
+	n := len(placeholders)
+	for i := range q.chunks {
+		if q.chunks[i].bufLow > p {
+			q.chunks[i].bufLow += n
+			q.chunks[i].bufHigh += n
+		}
+	}
+	chunk.bufHigh += n
+
+	// Insert arguments after the ones of the IN expression
+	argNo := 0
+	for _, c := range q.chunks[:index+1] {
+		argNo += c.argLen
+	}
+	q.args = insertAt(q.args, args, argNo)
+	chunk.argLen += len(args)
+	q.Invalidate()
+	return q
+}
+
+// inListTail returns the length of a placeholder list of an IN
+// expression s ends with, or -1 if s doesn't end with an IN expression.
+func inListTail(s []byte) int {
+	if len(s) == 0 || s[len(s)-1] != ')' {
+		return -1
+	}
+	i := bytes.LastIndex(s, []byte("IN ("))
+	if i < 0 {
+		return -1
+	}
+	list := s[i+4 : len(s)-1]
+	for _, c := range list {
+		if c != '?' && c != ',' {
+			return -1
+		}
+	}
+	return len(list)
+}
+
+/*
+InNullable is a version of In method that renders nil arguments
+as NULL literals instead of passing them to a database driver:
+
+	q.Where("parent_id").InNullable(1, nil, 2)
+
+produces
+
+	WHERE parent_id IN (?,NULL,?)
+
+Note that comparison with NULL is never true, use IS NULL
+to match NULL values.
+*/
+func (q *Stmt) InNullable(args ...interface{}) *Stmt {
+	values := make([]interface{}, 0, len(args))
+	buf := bytebufferpool.Get()
+	buf.WriteString("IN (")
+	for i, arg := range args {
+		if i > 0 {
+			buf.WriteByte(',')
+		}
+		if arg == nil {
+			buf.WriteString("NULL")
+			continue
+		}
+		buf.WriteByte('?')
+		values = append(values, arg)
+	}
+	buf.WriteString(")")
+
+	q.addChunk(posWhere, "", bufToString(&buf.B), values, " ")
+
+	bytebufferpool.Put(buf)
+	return q
+}
+
+/*
+WhereInSubquery adds a filter matching column against
+values returned by a subquery:
+
+	q.WhereInSubquery("user_id", sqlf.From("bans").Select("user_id").Where("until > ?", now))
+
+produces
+
+	WHERE user_id IN (SELECT user_id FROM bans WHERE until > ?)
+
+WhereInSubquery calls a Close method of a given query.
+*/
+func (q *Stmt) WhereInSubquery(column string, query *Stmt) *Stmt {
+	q.addChunk(posWhere, "WHERE", "", nil, "")
+	return q.SubQuery(column+" IN (", ")", query)
+}
+
+// WhereNotInSubquery is a NOT IN version of WhereInSubquery method.
+func (q *Stmt) WhereNotInSubquery(column string, query *Stmt) *Stmt {
+	q.addChunk(posWhere, "WHERE", "", nil, "")
+	return q.SubQuery(column+" NOT IN (", ")", query)
+}
+
+/*
+WhereAny adds a filter matching column against a list of values.
+
+PostgreSQL dialect passes values as a single argument:
+
+	WHERE id = ANY($1)
+
+Wrap values with pq.Array or a similar helper if a driver requires it.
+
+Other dialects expand a slice or an array passed as values to
+a list of arguments:
+
+	WHERE id IN (?,?,?)
+*/
+func (q *Stmt) WhereAny(column string, values interface{}) *Stmt {
+	return q.WhereOp(column, "=", Any, values)
+}
+
+// Quantifier is an ANY or ALL keyword of an array comparison.
+type Quantifier string
+
+const (
+	// Any makes a comparison true if it's true for any array element.
+	Any Quantifier = "ANY"
+	// All makes a comparison true if it's true for all array elements.
+	All Quantifier = "ALL"
+)
+
+/*
+WhereOp adds a filter comparing column to array elements:
+
+	q.WhereOp("price", ">", sqlf.All, prices)
+
+produces
+
+	WHERE price > ALL(?)
+
+The array is passed as a single argument.
+
+Dialects other than PostgreSQL expand a slice or an array passed as values
+to a list of arguments for = ANY and <> ALL comparisons:
+
+	WHERE id IN (?,?,?)
+	WHERE id NOT IN (?,?,?)
+*/
+func (q *Stmt) WhereOp(column, op string, quantifier Quantifier, values interface{}) *Stmt {
+	if q.dialect != PostgreSQL {
+		switch {
+		case op == "=" && quantifier == Any:
+			return q.Where(column).In(sliceArgs(values)...)
+		case op == "<>" && quantifier == All:
+			return q.Where(column + " NOT").In(sliceArgs(values)...)
+		}
+	}
+	return q.Where(column+" "+op+" "+string(quantifier)+"(?)", values)
+}
+
+/*
+WhereLike adds a LIKE filter:
+
+	q.WhereLike("name", "John%")
+
+produces
+
+	WHERE name LIKE ?
+
+Use EscapeLike to match a user-supplied string literally.
+*/
+func (q *Stmt) WhereLike(column, pattern string) *Stmt {
+	return q.Where(column+" LIKE ?", pattern)
+}
+
+/*
+WhereILike adds a case-insensitive LIKE filter.
+
+PostgreSQL dialect produces
+
+	WHERE name ILIKE $1
+
+Other dialects fall back to
+
+	WHERE LOWER(name) LIKE LOWER(?)
+*/
+func (q *Stmt) WhereILike(column, pattern string) *Stmt {
+	if q.dialect == PostgreSQL {
+		return q.Where(column+" ILIKE ?", pattern)
+	}
+	return q.Where("LOWER("+column+") LIKE LOWER(?)", pattern)
+}
+
+/*
+WhereAnyColumnLike adds a filter matching any of columns against
+a case-insensitive pattern:
+
+	q.WhereAnyColumnLike([]string{"name", "email"}, "%john%")
+
+PostgreSQL dialect produces
+
+	WHERE (name ILIKE $1 OR email ILIKE $2)
+
+Other dialects produce
+
+	WHERE (LOWER(name) LIKE LOWER(?) OR LOWER(email) LIKE LOWER(?))
+
+The pattern is passed as an argument once per column.
+WhereAnyColumnLike does nothing if no columns are passed.
+*/
+func (q *Stmt) WhereAnyColumnLike(columns []string, pattern string) *Stmt {
+	if len(columns) == 0 {
+		return q
+	}
+	conds := make([]Cond, len(columns))
+	for i, column := range columns {
+		if q.dialect == PostgreSQL {
+			conds[i] = Expr(column+" ILIKE ?", pattern)
+		} else {
+			conds[i] = Expr("LOWER("+column+") LIKE LOWER(?)", pattern)
+		}
+	}
+	return q.WhereCond(conds[0].Or(conds[1:]...))
+}
+
+/*
+WhereKeyset adds a filter matching any of composite key values:
+
+	q.WhereKeyset([]string{"order_id", "line_no"}, [][]interface{}{
+		{1, 10},
+		{2, 20},
+	})
+
+produces
+
+	WHERE ((order_id = ? AND line_no = ?) OR (order_id = ? AND line_no = ?))
+
+Each tuple must contain a value for every column, WhereKeyset records
+an error otherwise, see Err.
+An empty list of tuples adds a condition that is always false.
+*/
+func (q *Stmt) WhereKeyset(columns []string, tuples [][]interface{}) *Stmt {
+	if err := checkTuples("WhereKeyset", columns, tuples); err != nil {
+		q.setErr(err)
+		return q
+	}
+	if len(tuples) == 0 {
+		return q.Where("1 = 0")
+	}
+	conds := make([]Cond, len(tuples))
+	for i, tuple := range tuples {
+		eqs := make([]Cond, len(columns))
+		for j, column := range columns {
+			eqs[j] = Expr(column+" = ?", tuple[j])
+		}
+		conds[i] = eqs[0].And(eqs[1:]...)
+	}
+	return q.WhereCond(conds[0].Or(conds[1:]...))
+}
+
+/*
+WhereTupleIn adds a filter matching a row of columns against a list of tuples:
+
+	q.WhereTupleIn([]string{"order_id", "line_no"}, [][]interface{}{
+		{1, 10},
+		{2, 20},
+	})
+
+produces
+
+	WHERE (order_id, line_no) IN ((?, ?), (?, ?))
+
+Each tuple must contain a value for every column, WhereTupleIn records
+an error otherwise, see Err.
+An empty list of tuples adds a condition that is always false.
+Row value comparisons are supported by PostgreSQL, MySQL and SQLite 3.15+.
+*/
+func (q *Stmt) WhereTupleIn(columns []string, tuples [][]interface{}) *Stmt {
+	if err := checkTuples("WhereTupleIn", columns, tuples); err != nil {
+		q.setErr(err)
+		return q
+	}
+	if len(tuples) == 0 {
+		return q.Where("1 = 0")
+	}
+	var buf strings.Builder
+	args := make([]interface{}, 0, len(tuples)*len(columns))
+	buf.WriteByte('(')
+	buf.WriteString(strings.Join(columns, ", "))
+	buf.WriteString(") IN (")
+	for i, tuple := range tuples {
+		if i > 0 {
+			buf.WriteString(", ")
+		}
+		buf.WriteByte('(')
+		for j := range columns {
+			if j > 0 {
+				buf.WriteString(", ")
+			}
+			buf.WriteByte('?')
+			args = append(args, tuple[j])
+		}
+		buf.WriteByte(')')
+	}
+	buf.WriteByte(')')
+	return q.Where(buf.String(), args...)
+}
+
+// checkTuples returns an error if there are no columns
+// or a tuple doesn't have a value for every column.
+func checkTuples(method string, columns []string, tuples [][]interface{}) error {
+	if len(columns) == 0 {
+		return fmt.Errorf("sqlf: %s is called with no columns", method)
+	}
+	for i, tuple := range tuples {
+		if len(tuple) != len(columns) {
+			return fmt.Errorf("sqlf: %s tuple %d has %d value(s) for %d column(s)", method, i+1, len(tuple), len(columns))
+		}
+	}
+	return nil
+}
+
+/*
+EscapeLike escapes %, _ and \ characters of s with a backslash,
+so s can be matched literally by LIKE and ILIKE expressions:
+
+	q.WhereLike("name", sqlf.EscapeLike(prefix)+"%")
+
+PostgreSQL and MySQL treat a backslash as an escape character by default.
+Other databases may require an ESCAPE clause.
+*/
+func EscapeLike(s string) string {
+	if !strings.ContainsAny(s, `%_\`) {
+		return s
+	}
+	var b strings.Builder
+	b.Grow(len(s) + 4)
+	for i := 0; i < len(s); i++ {
+		switch c := s[i]; c {
+		case '%', '_', '\\':
+			b.WriteByte('\\')
+			b.WriteByte(c)
+		default:
+			b.WriteByte(c)
+		}
+	}
+	return b.String()
+}
+
+/*
+Join adds an INNERT JOIN clause to SELECT statement
+*/
+func (q *Stmt) Join(table, on string) *Stmt {
+	q.join("JOIN ", table, on)
+	return q
+}
+
+/*
+LeftJoin adds a LEFT OUTER JOIN clause to SELECT statement
+*/
+func (q *Stmt) LeftJoin(table, on string) *Stmt {
+	q.join("LEFT JOIN ", table, on)
+	return q
+}
+
+/*
+RightJoin adds a RIGHT OUTER JOIN clause to SELECT statement
+*/
+func (q *Stmt) RightJoin(table, on string) *Stmt {
+	q.join("RIGHT JOIN ", table, on)
+	return q
+}
+
+/*
+FullJoin adds a FULL OUTER JOIN clause to SELECT statement
+*/
+func (q *Stmt) FullJoin(table, on string) *Stmt {
+	q.join("FULL JOIN ", table, on)
+	return q
+}
+
+/*
+JoinIf adds an INNER JOIN clause to SELECT statement if cond is true.
+
+	q := sqlf.From("orders o").
+		Select("o.id").
+		JoinIf(withUsers, "users u", "u.id = o.user_id")
+*/
+func (q *Stmt) JoinIf(cond bool, table, on string) *Stmt {
+	if cond {
+		q.join("JOIN ", table, on)
+	}
+	return q
+}
+
+/*
+LeftJoinIf adds a LEFT OUTER JOIN clause to SELECT statement if cond is true.
+*/
+func (q *Stmt) LeftJoinIf(cond bool, table, on string) *Stmt {
+	if cond {
+		q.join("LEFT JOIN ", table, on)
+	}
+	return q
+}
+
+/*
+RightJoinIf adds a RIGHT OUTER JOIN clause to SELECT statement if cond is true.
+*/
+func (q *Stmt) RightJoinIf(cond bool, table, on string) *Stmt {
+	if cond {
+		q.join("RIGHT JOIN ", table, on)
+	}
+	return q
+}
+
+/*
+FullJoinIf adds a FULL OUTER JOIN clause to SELECT statement if cond is true.
+*/
+func (q *Stmt) FullJoinIf(cond bool, table, on string) *Stmt {
+	if cond {
+		q.join("FULL JOIN ", table, on)
+	}
+	return q
+}
+
+/*
+CrossApply adds a CROSS APPLY clause to SELECT statement (SQL Server).
+
+	q := sqlf.From("users u").
+		Select("u.name, o.total").
+		CrossApply(sqlf.From("orders").
+			Select("TOP 1 total").
+			Where("user_id = u.id").
+			OrderBy("total DESC"), "o")
+
+produces
+
+	SELECT u.name, o.total FROM users u CROSS APPLY (SELECT TOP 1 total FROM orders WHERE user_id = u.id ORDER BY total DESC) o
+
+CrossApply method call closes the Stmt passed as query parameter.
+Do not reuse it afterwards.
+*/
+func (q *Stmt) CrossApply(query *Stmt, alias string) *Stmt {
+	q.apply("CROSS APPLY (", query, alias)
+	return q
+}
+
+/*
+OuterApply adds an OUTER APPLY clause to SELECT statement (SQL Server).
+
+OuterApply method call closes the Stmt passed as query parameter.
+Do not reuse it afterwards.
+*/
+func (q *Stmt) OuterApply(query *Stmt, alias string) *Stmt {
+	q.apply("OUTER APPLY (", query, alias)
+	return q
+}
+
+// OrderBy adds the ORDER BY clause to SELECT statement
+func (q *Stmt) OrderBy(expr ...string) *Stmt {
+	q.addChunk(posOrderBy, "ORDER BY", strings.Join(expr, ", "), nil, ", ")
+	return q
+}
+
+/*
+OrderBySpec adds an ORDER BY clause defined by a sort specification
+coming from a user, like a "sort" query parameter of an API call:
+
+	allowed := map[string]string{"name": "u.name", "created": "u.created_at"}
+	if err := q.OrderBySpec("name,-created", allowed); err != nil {
+		return err
+	}
+
+produces
+
+	ORDER BY u.name, u.created_at DESC
+
+Fields are separated with commas, a leading minus sign sorts
+a field in descending order. allowed maps field names to columns.
+
+OrderBySpec returns an error and leaves the statement intact if any of
+fields is not allowed.
+*/
+func (q *Stmt) OrderBySpec(spec string, allowed map[string]string) error {
+	var exprs []string
+	for _, field := range strings.Split(spec, ",") {
+		field = strings.TrimSpace(field)
+		if field == "" {
+			continue
+		}
+		desc := strings.HasPrefix(field, "-")
+		if desc {
+			field = field[1:]
+		}
+		column, ok := allowed[field]
+		if !ok {
+			return fmt.Errorf("sqlf: sort field %q is not allowed", field)
+		}
+		if desc {
+			column += " DESC"
+		}
+		exprs = append(exprs, column)
+	}
+	if len(exprs) > 0 {
+		q.OrderBy(exprs...)
+	}
+	return nil
+}
+
+/*
+OrderByRandom adds random ordering to the ORDER BY clause.
+
+MySQL dialect produces ORDER BY RAND(), other dialects
+produce ORDER BY RANDOM().
+*/
+func (q *Stmt) OrderByRandom() *Stmt {
+	if q.dialect == MySQL {
+		return q.OrderBy("RAND()")
+	}
+	return q.OrderBy("RANDOM()")
+}
+
+/*
+OrderByCollate adds a column to the ORDER BY clause
+sorted according to a given collation:
+
+	q.OrderByCollate("name", `"C"`)
+
+produces
+
+	ORDER BY name COLLATE "C"
+
+Collation names are database-specific and are not quoted automatically.
+*/
+func (q *Stmt) OrderByCollate(column, collation string) *Stmt {
+	return q.OrderBy(Collate(column, collation))
+}
+
+/*
+Collate appends a COLLATE clause to an expression.
+
+Use it to build comparisons for Where and other methods
+that accept column names or SQL fragments:
+
+	q.Where(sqlf.Collate("name = ?", "NOCASE"), name)
+	q.WhereLike(sqlf.Collate("title", `"en_US"`), "%go%")
+
+Collation names are database-specific and are not quoted automatically.
+*/
+func Collate(expr, collation string) string {
+	return expr + " COLLATE " + collation
+}
+
+// GroupBy adds the GROUP BY clause to SELECT statement
+func (q *Stmt) GroupBy(expr ...string) *Stmt {
+	q.addChunk(posGroupBy, "GROUP BY", strings.Join(expr, ", "), nil, ", ")
+	return q
+}
+
+/*
+GroupByRollup adds a ROLLUP grouping to the GROUP BY clause:
+
+	q.GroupByRollup("region", "product")
+
+produces
+
+	GROUP BY ROLLUP (region, product)
+*/
+func (q *Stmt) GroupByRollup(columns ...string) *Stmt {
+	return q.GroupBy("ROLLUP (" + strings.Join(columns, ", ") + ")")
+}
+
+/*
+GroupByCube adds a CUBE grouping to the GROUP BY clause:
+
+	q.GroupByCube("region", "product")
+
+produces
+
+	GROUP BY CUBE (region, product)
+*/
+func (q *Stmt) GroupByCube(columns ...string) *Stmt {
+	return q.GroupBy("CUBE (" + strings.Join(columns, ", ") + ")")
+}
+
+/*
+GroupByGroupingSets adds a GROUPING SETS grouping to the GROUP BY clause.
+Every set is a list of columns, an empty set stands for a grand total:
+
+	q.GroupByGroupingSets([]string{"region", "product"}, []string{"region"}, nil)
+
+produces
+
+	GROUP BY GROUPING SETS ((region, product), (region), ())
+*/
+func (q *Stmt) GroupByGroupingSets(sets ...[]string) *Stmt {
+	var buf strings.Builder
+	buf.WriteString("GROUPING SETS (")
+	for i, set := range sets {
+		if i > 0 {
+			buf.WriteString(", ")
+		}
+		buf.WriteByte('(')
+		buf.WriteString(strings.Join(set, ", "))
+		buf.WriteByte(')')
+	}
+	buf.WriteByte(')')
+	return q.GroupBy(buf.String())
+}
+
+// Having adds the HAVING clause to SELECT statement
+func (q *Stmt) Having(expr string, args ...interface{}) *Stmt {
+	expr, args = q.indexArgs(expr, args)
 	q.addChunk(posHaving, "HAVING", expr, args, " AND ")
 	return q
 }
 
-// Limit adds a limit on number of returned rows
+// HavingCond adds a HAVING condition defined by a Cond value.
+func (q *Stmt) HavingCond(c Cond) *Stmt {
+	return q.Having(c.Expr, c.Args...)
+}
+
+/*
+OrHaving adds a HAVING condition joined with OR:
+
+	q.Having("SUM(amount) > ?", 1000).
+		OrHaving("COUNT(*) > ?", 10)
+
+produces
+
+	HAVING SUM(amount) > ? OR COUNT(*) > ?
+
+Note that AND takes precedence over OR. Wrap conditions in parentheses
+when mixing Having and OrHaving calls if needed.
+*/
+func (q *Stmt) OrHaving(expr string, args ...interface{}) *Stmt {
+	expr, args = q.indexArgs(expr, args)
+	q.addChunk(posHaving, "HAVING", expr, args, " OR ")
+	return q
+}
+
+/*
+Limit adds a limit on number of returned rows.
+
+limit is passed to a database driver as an argument, so it can be
+of any type supported by a driver. Use LimitExpr to limit the number
+of rows with an SQL expression.
+*/
 func (q *Stmt) Limit(limit interface{}) *Stmt {
+	// Replace a LIMIT clause added by LimitExpr
+	if i := q.findChunk(posLimit); i >= 0 && q.chunks[i].argLen != 1 {
+		q.removeChunks(posLimit, posLimit)
+	}
 	q.addChunk(posLimit, "LIMIT ?", "", []interface{}{limit}, "")
 	return q
 }
 
+/*
+LimitExpr adds a limit on number of returned rows defined by an SQL expression:
+
+	q.LimitExpr("(SELECT max_rows FROM settings)")
+
+LimitExpr replaces a LIMIT clause added earlier.
+*/
+func (q *Stmt) LimitExpr(expr string, args ...interface{}) *Stmt {
+	expr, args = q.indexArgs(expr, args)
+	q.removeChunks(posLimit, posLimit)
+	q.addChunk(posLimit, "LIMIT", expr, args, "")
+	return q
+}
+
+/*
+LimitAll removes a limit on number of returned rows.
+
+PostgreSQL dialect produces a LIMIT ALL clause, other dialects
+remove a LIMIT clause. Note that some databases, like MySQL and SQLite,
+don't accept OFFSET without LIMIT.
+*/
+func (q *Stmt) LimitAll() *Stmt {
+	if q.dialect == PostgreSQL {
+		return q.LimitExpr("ALL")
+	}
+	q.removeChunks(posLimit, posLimit)
+	return q
+}
+
+/*
+FetchFirst adds a FETCH FIRST clause limiting the number of returned rows:
+
+	q.OrderBy("score DESC").FetchFirst(10, true)
+
+produces
+
+	ORDER BY score DESC FETCH FIRST ? ROWS WITH TIES
+
+FetchFirst and Limit are mutually exclusive. FetchFirst replaces a LIMIT
+clause added earlier. A subsequent Limit call changes the number of rows
+to be fetched, keeping the FETCH FIRST clause.
+
+The clause takes the place of LIMIT, before OFFSET. PostgreSQL accepts
+such order; databases requiring OFFSET to go first need a Clause call instead.
+*/
+func (q *Stmt) FetchFirst(n interface{}, withTies bool) *Stmt {
+	q.removeChunks(posLimit, posLimit)
+	if withTies {
+		q.addChunk(posLimit, "FETCH FIRST ? ROWS WITH TIES", "", []interface{}{n}, "")
+	} else {
+		q.addChunk(posLimit, "FETCH FIRST ? ROWS ONLY", "", []interface{}{n}, "")
+	}
+	return q
+}
+
 // Offset adds a limit on number of returned rows
 func (q *Stmt) Offset(offset interface{}) *Stmt {
 	q.addChunk(posOffset, "OFFSET ?", "", []interface{}{offset}, "")
 	return q
 }
 
+/*
+SetLimitOffset sets both LIMIT and OFFSET argument values.
+
+If a statement already has both clauses, only the arguments change,
+and the SQL built before is reused. Use it to fetch pages of a clone
+of a statement without rebuilding it:
+
+	page := base.Clone().SetLimitOffset(pageSize, n*pageSize)
+
+Clauses missing from a statement are added.
+*/
+func (q *Stmt) SetLimitOffset(limit, offset int) *Stmt {
+	return q.Limit(limit).Offset(offset)
+}
+
+/*
+ToCount returns a statement counting rows the statement returns.
+The statement itself is not modified.
+
+The SELECT list is replaced with COUNT(*), and ORDER BY, LIMIT and
+OFFSET clauses are dropped:
+
+	q := sqlf.From("orders").
+		Select("id, amount").
+		Where("user_id = ?", userID).
+		OrderBy("id").
+		Limit(10)
+	total := q.ToCount()
+
+produces
+
+	SELECT COUNT(*) FROM orders WHERE user_id = ?
+
+A statement with GROUP BY, HAVING, DISTINCT or combined queries
+is wrapped into a subquery:
+
+	SELECT COUNT(*) FROM (SELECT user_id FROM orders GROUP BY user_id) x
+
+Scan targets of the statement are not copied.
+*/
+func (q *Stmt) ToCount() *Stmt {
+	c := q.Clone()
+	for i := range c.dest {
+		c.dest[i] = nil
+	}
+	c.dest = c.dest[:0]
+	c.destStruct = nil
+	c.removeChunks(posOrderBy, posOffset)
+
+	wrap := strings.HasPrefix(c.chunkText(posSelect), "SELECT DISTINCT")
+	for _, chunk := range c.chunks {
+		if chunk.pos >= posGroupBy && chunk.pos < posOrderBy {
+			wrap = true
+		}
+	}
+	if !wrap {
+		c.removeChunks(posSelect, posSelect)
+		c.addChunk(posSelect, "SELECT", "COUNT(*)", nil, ", ")
+		return c
+	}
+
+	count := getStmt(q.dialect)
+	count.nullAsZero = q.nullAsZero
+	count.addChunk(posSelect, "SELECT", "COUNT(*)", nil, ", ")
+	count.addChunk(posFrom, "FROM", "", nil, "")
+	return count.SubQuery("(", ") x", c)
+}
+
 // Paginate provides an easy way to set both offset and limit
 func (q *Stmt) Paginate(page, pageSize int) *Stmt {
 	if page < 1 {
@@ -450,6 +1798,24 @@ func (q *Stmt) Returning(expr string) *Stmt {
 	return q
 }
 
+/*
+ReturningInserted adds a column to a RETURNING clause of PostgreSQL
+upsert statement to tell if a row was inserted or updated:
+
+	var inserted bool
+	err := sqlf.PostgreSQL.InsertInto("counters").
+		Set("id", id).
+		Set("value", 1).
+		Clause("ON CONFLICT (id) DO UPDATE SET value = counters.value + 1").
+		ReturningInserted(&inserted).
+		QueryRowAndClose(ctx, db)
+
+dest is set to true if a row was inserted.
+*/
+func (q *Stmt) ReturningInserted(dest *bool) *Stmt {
+	return q.Returning("(xmax = 0) AS inserted").To(dest)
+}
+
 // With prepends a statement with an WITH clause.
 // With method calls a Close method of a given query, so
 // make sure not to reuse it afterwards.
@@ -458,127 +1824,578 @@ func (q *Stmt) With(queryName string, query *Stmt) *Stmt {
 	return q.SubQuery(queryName+" AS (", ")", query)
 }
 
+/*
+WithMaterialized prepends a statement with an WITH clause
+controlling whether PostgreSQL computes a query result once:
+
+	q.WithMaterialized("totals", true, sqlf.From("orders").Select("SUM(amount)"))
+
+produces
+
+	WITH totals AS MATERIALIZED (SELECT SUM(amount) FROM orders)
+
+Pass false to get AS NOT MATERIALIZED. Other dialects ignore the hint
+and produce a plain WITH clause.
+
+WithMaterialized calls a Close method of a given query.
+*/
+func (q *Stmt) WithMaterialized(queryName string, materialized bool, query *Stmt) *Stmt {
+	if q.dialect != PostgreSQL {
+		return q.With(queryName, query)
+	}
+	hint := " AS MATERIALIZED ("
+	if !materialized {
+		hint = " AS NOT MATERIALIZED ("
+	}
+	q.addChunk(posWith, "WITH", "", nil, "")
+	return q.SubQuery(queryName+hint, ")", query)
+}
+
+/*
+WithColumns prepends a statement with an WITH clause
+naming the columns of a query result:
+
+	q.WithColumns("RECURSIVE t", []string{"n"},
+		sqlf.New("VALUES (1)").
+			Union(true, sqlf.From("t").Select("n + 1").Where("n < ?", 100)))
+
+produces
+
+	WITH RECURSIVE t(n) AS (VALUES (1) UNION ALL SELECT n + 1 FROM t WHERE n < ?)
+
+WithColumns calls a Close method of a given query.
+*/
+func (q *Stmt) WithColumns(queryName string, columns []string, query *Stmt) *Stmt {
+	return q.With(queryName+"("+strings.Join(columns, ", ")+")", query)
+}
+
+/*
+WithRecursive prepends a statement with a recursive WITH clause
+combining base and recursive terms with UNION or UNION ALL:
+
+	q := sqlf.PostgreSQL.From("subordinates").
+		WithRecursive("subordinates",
+			sqlf.From("employees").Select("id, manager_id").Where("id = ?", 1),
+			sqlf.From("employees e").
+				Select("e.id, e.manager_id").
+				Join("subordinates s", "e.manager_id = s.id").
+				Where("e.is_active = ?", true),
+			true).
+		Select("id")
+
+produces
+
+	WITH RECURSIVE subordinates AS (SELECT id, manager_id FROM employees WHERE id = $1 UNION ALL SELECT e.id, e.manager_id FROM employees e JOIN subordinates s ON (e.manager_id = s.id) WHERE e.is_active = $2) SELECT id FROM subordinates
+
+Pass queryName like "t(n)" to name the query columns.
+Other queries of a WITH clause may be added by With calls,
+WITH RECURSIVE allows both recursive and non-recursive queries.
+
+WithRecursive calls a Close method of given queries.
+*/
+func (q *Stmt) WithRecursive(queryName string, base, recursive *Stmt, all bool) *Stmt {
+	text := q.chunkText(posWith)
+	if text != "" && !strings.HasPrefix(text, "WITH RECURSIVE") {
+		q.replaceVerb(posWith, "WITH", "WITH RECURSIVE")
+	} else {
+		q.addChunk(posWith, "WITH RECURSIVE", "", nil, "")
+	}
+	return q.SubQuery(queryName+" AS (", ")", base.Union(all, recursive))
+}
+
 /*
 Expr appends an expression to the most recently added clause.
 
 Expressions are separated with commas.
 */
 func (q *Stmt) Expr(expr string, args ...interface{}) *Stmt {
+	expr, args = q.indexArgs(expr, args)
 	q.addChunk(q.pos, "", expr, args, ", ")
 	return q
 }
 
+/*
+ExprSep appends an expression to the most recently added clause
+using a given separator:
+
+	q.Select("first_name").ExprSep(" || ' ' || ", "last_name")
+
+produces
+
+	SELECT first_name || ' ' || last_name
+*/
+func (q *Stmt) ExprSep(sep, expr string, args ...interface{}) *Stmt {
+	expr, args = q.indexArgs(expr, args)
+	q.addChunk(q.pos, "", expr, args, sep)
+	return q
+}
+
+/*
+AppendTo appends an expression to a given clause of a statement
+no matter what clause was added most recently.
+
+	q := sqlf.From("table").
+		Select("id").
+		Where("id > ?", 42).
+		OrderBy("id")
+	// ...
+	q.AppendTo("SELECT", "name")
+
+Supported clauses are SELECT, FROM, WHERE, GROUP BY, HAVING, ORDER BY,
+SET and RETURNING. Expressions are joined with AND for WHERE and HAVING
+clauses and with commas for others. The clause is added to a statement
+if it's not there yet.
+
+Any other clause is added the way Clause method does.
+*/
+func (q *Stmt) AppendTo(clause, expr string, args ...interface{}) *Stmt {
+	c, ok := clauses[strings.ToUpper(clause)]
+	if !ok {
+		return q.Clause(clause).Expr(expr, args...)
+	}
+	expr, args = q.indexArgs(expr, args)
+	q.addChunk(c.pos, c.name, expr, args, c.sep)
+	return q
+}
+
+/*
+HasClause reports whether a statement has a given clause:
+
+	if !q.HasClause("ORDER BY") {
+		q.OrderBy("id")
+	}
+
+Supported clauses are the ones AppendTo accepts, along with
+WITH, LIMIT and OFFSET. HasClause returns false for any other clause.
+*/
+func (q *Stmt) HasClause(clause string) bool {
+	pos, ok := clausePos(clause)
+	return ok && q.findChunk(pos) >= 0
+}
+
+/*
+ClauseText returns an SQL fragment of a given clause the way
+it appears in a built statement:
+
+	q := sqlf.PostgreSQL.From("users").
+		Select("id").
+		Where("status = ?", "new").
+		Where("age > ?", 18)
+	where, ok := q.ClauseText("WHERE")
+
+returns
+
+	WHERE status = $1 AND age > $2
+
+ClauseText supports the same clauses HasClause does and
+returns false if a statement doesn't have a given clause.
+*/
+func (q *Stmt) ClauseText(clause string) (string, bool) {
+	pos, ok := clausePos(clause)
+	if !ok || q.findChunk(pos) < 0 {
+		return "", false
+	}
+	var buf strings.Builder
+	argNo := 1
+	for _, chunk := range q.chunks {
+		if chunk.pos != pos {
+			argNo += chunk.argLen
+			continue
+		}
+		argNo = q.writeChunk(argNo, chunk, &buf)
+	}
+	return buf.String(), true
+}
+
+// clausePos returns a position of chunks holding a given clause.
+func clausePos(clause string) (chunkPos, bool) {
+	switch clause = strings.ToUpper(clause); clause {
+	case "WITH":
+		return posWith, true
+	case "LIMIT":
+		return posLimit, true
+	case "OFFSET":
+		return posOffset, true
+	}
+	c, ok := clauses[clause]
+	return c.pos, ok
+}
+
 /*
 SubQuery appends a sub query expression to a current clause.
 
 SubQuery method call closes the Stmt passed as query parameter.
 Do not reuse it afterwards.
+
+Subquery arguments are appended to the statement arguments and
+placeholders are numbered as a whole, even if the subquery has been
+built for PostgreSQL or rendered with String before.
+Don't embed the SQL text of a PostgreSQL subquery into an expression
+with fmt.Sprintf, its $1, $2, ... placeholders won't be renumbered.
 */
 func (q *Stmt) SubQuery(prefix, suffix string, query *Stmt) *Stmt {
 	delimiter := ", "
 	if q.pos == posWhere {
 		delimiter = " AND "
 	}
-	index := q.addChunk(q.pos, "", prefix, query.args, delimiter)
-	chunk := &q.chunks[index]
-	// Make sure subquery is not dialect-specific.
-	if query.dialect != NoDialect {
-		query.dialect = NoDialect
-		query.Invalidate()
+	index := q.addChunk(q.pos, "", prefix, query.args, delimiter)
+	chunk := &q.chunks[index]
+	q.writeSubQuery(index, query)
+	q.buf.WriteString(suffix)
+	chunk.bufHigh = q.buf.Len()
+
+	return q
+}
+
+/*
+Union adds a UNION clause to the statement.
+
+all argument controls if UNION ALL or UNION clause
+is to be constructed. Use UNION ALL if possible to
+get faster queries.
+
+ORDER BY, LIMIT and OFFSET clauses of the statement are rendered after
+all combined queries and apply to the combined result, no matter if
+they are added before or after a Union call. A combined query is not
+enclosed in parentheses, so its own ORDER BY or LIMIT clause has to be
+moved to a subquery:
+
+	q.Union(true, sqlf.From("").
+		SubQuery("(", ") AS t", sqlf.From("tasks").Select("id").OrderBy("id").Limit(5)).
+		Select("id"))
+*/
+func (q *Stmt) Union(all bool, query *Stmt) *Stmt {
+	return q.combine("UNION", all, query)
+}
+
+/*
+Intersect adds an INTERSECT clause to the statement.
+
+all argument controls if INTERSECT ALL or INTERSECT clause
+is to be constructed.
+*/
+func (q *Stmt) Intersect(all bool, query *Stmt) *Stmt {
+	return q.combine("INTERSECT", all, query)
+}
+
+/*
+Except adds an EXCEPT clause to the statement.
+
+all argument controls if EXCEPT ALL or EXCEPT clause
+is to be constructed.
+*/
+func (q *Stmt) Except(all bool, query *Stmt) *Stmt {
+	return q.combine("EXCEPT", all, query)
+}
+
+// combine appends a query with a set operation like UNION.
+func (q *Stmt) combine(op string, all bool, query *Stmt) *Stmt {
+	// Keep combined queries in order, ahead of ORDER BY and LIMIT clauses
+	p := posUnion
+	for _, chunk := range q.chunks {
+		if chunk.pos >= p && chunk.pos < posOrderBy {
+			p = chunk.pos + 1
+		}
+	}
+	clause := op + " "
+	if all {
+		clause = op + " ALL "
+	}
+	index := q.addChunk(p, clause, "", query.args, "")
+	chunk := &q.chunks[index]
+	q.writeSubQuery(index, query)
+	chunk.bufHigh = q.buf.Len()
+
+	return q
+}
+
+/*
+Clause appends a raw SQL fragment to the statement.
+
+Use it to add a raw SQL fragment like ON CONFLICT, ON DUPLICATE KEY, WINDOW, etc.
+
+An SQL fragment added via Clause method appears after the last clause previously
+added. If called first, Clause method prepends a statement with a raw SQL.
+*/
+func (q *Stmt) Clause(expr string, args ...interface{}) *Stmt {
+	expr, args = q.indexArgs(expr, args)
+	p := posStart
+	if len(q.chunks) > 0 {
+		p = (&q.chunks[len(q.chunks)-1]).pos + 10
+	}
+	q.addChunk(p, expr, "", args, ", ")
+	return q
+}
+
+/*
+UseIndex adds a MySQL index hint to a table of a FROM clause:
+
+	q := sqlf.MySQL.From("orders").
+		UseIndex("idx_created_at").
+		Select("id").
+		Where("created_at > ?", since)
+
+produces
+
+	SELECT id FROM orders USE INDEX (idx_created_at) WHERE created_at > ?
+
+The hint is appended to the FROM clause, so call UseIndex right after
+From and before any joins. Other dialects ignore index hints.
+*/
+func (q *Stmt) UseIndex(index string) *Stmt {
+	if q.dialect == MySQL {
+		q.addChunk(posFrom, "", "USE INDEX ("+index+")", nil, " ")
+	}
+	return q
+}
+
+/*
+TableSample makes a query read a random sample of a table of
+a FROM clause:
+
+	q := sqlf.PostgreSQL.From("events").
+		TableSample("SYSTEM", 10).
+		Select("AVG(duration)")
+
+produces
+
+	SELECT AVG(duration) FROM events TABLESAMPLE SYSTEM ($1)
+
+method is a sampling method, like SYSTEM or BERNOULLI, pct is
+a percentage of table rows to be returned.
+
+The sample clause is appended to the FROM clause, so call TableSample
+right after From and before any joins. MySQL and SQLite have no
+TABLESAMPLE clause, TableSample records an error for these dialects,
+see Err.
+*/
+func (q *Stmt) TableSample(method string, pct float64) *Stmt {
+	if q.dialect == MySQL || q.dialect == SQLite {
+		q.setErr(errors.New("sqlf: TABLESAMPLE is not supported by MySQL and SQLite"))
+		return q
+	}
+	q.addChunk(posFrom, "", "TABLESAMPLE "+method+" (?)", []interface{}{pct}, " ")
+	return q
+}
+
+// Hint adds an optimizer hint comment right after a statement verb:
+//
+//	q := sqlf.Select("id").
+//		From("orders o").
+//		Hint("INDEX(o idx_created_at)")
+//
+// produces
+//
+//	SELECT /*+ INDEX(o idx_created_at) */ id FROM orders o
+//
+// This is the placement expected by Oracle and MySQL optimizers.
+// Hint must be called after Select, InsertInto, Update or DeleteFrom.
+// Comment delimiters are removed from text the way Comment does it.
+func (q *Stmt) Hint(text string) *Stmt {
+	for strings.Contains(text, "*/") || strings.Contains(text, "/*") {
+		text = strings.Replace(text, "*/", "", -1)
+		text = strings.Replace(text, "/*", "", -1)
+	}
+	for _, chunk := range q.chunks {
+		switch chunk.pos {
+		case posInsert, posDelete, posUpdate, posSelect:
+			verb := strings.Fields(q.chunkText(chunk.pos))[0]
+			q.replaceVerb(chunk.pos, verb, verb+" /*+ "+text+" */")
+			return q
+		}
+	}
+	return q
+}
+
+// Comment prepends a statement with an SQL comment:
+//
+//	q := sqlf.From("orders").
+//		Comment("service:orders,route:list").
+//		Select("id")
+//
+// produces
+//
+//	/* service:orders,route:list */ SELECT id FROM orders
+//
+// Comment delimiters are removed from text, so it can't end a comment
+// prematurely. Question marks in a comment are not treated as placeholders.
+func (q *Stmt) Comment(text string) *Stmt {
+	for strings.Contains(text, "*/") || strings.Contains(text, "/*") {
+		text = strings.Replace(text, "*/", "", -1)
+		text = strings.Replace(text, "/*", "", -1)
+	}
+	// Escape question marks unless a dialect never renumbers placeholders.
+	// NoDialect statements are escaped too, as they can be embedded
+	// into PostgreSQL ones as subqueries.
+	escaped := q.dialect != MySQL && q.dialect != SQLite && strings.Contains(text, "?")
+	if escaped {
+		text = strings.Replace(text, "?", "\\?", -1)
+	}
+	// Comments go ahead of any other clause
+	index := q.addChunk(posStart-2, "", "/* "+text+" */", nil, " ")
+	if escaped {
+		q.chunks[index].escaped = true
+	}
+	return q
+}
+
+/*
+Explain prepends a statement with an EXPLAIN clause to get its
+execution plan instead of results:
+
+	q := sqlf.PostgreSQL.From("orders").Select("id").Where("user_id = ?", 42)
+	q.Explain(true)
+
+produces
+
+	EXPLAIN ANALYZE SELECT id FROM orders WHERE user_id = $1
+
+Statement arguments are kept. Set analyze to execute a statement and
+get actual run times. PostgreSQL and MySQL produce EXPLAIN ANALYZE for it.
+Otherwise MySQL produces EXPLAIN FORMAT=JSON and SQLite produces
+EXPLAIN QUERY PLAN, which doesn't support analyze.
+
+Clone a statement to keep the original one intact:
+
+	plan := q.Clone().Explain(false)
+*/
+func (q *Stmt) Explain(analyze bool) *Stmt {
+	explain := "EXPLAIN"
+	switch {
+	case q.dialect == SQLite:
+		explain = "EXPLAIN QUERY PLAN"
+	case analyze:
+		explain = "EXPLAIN ANALYZE"
+	case q.dialect == MySQL:
+		explain = "EXPLAIN FORMAT=JSON"
+	}
+	q.addChunk(posStart-1, explain, "", nil, "")
+	return q
+}
+
+// String method builds and returns an SQL statement.
+func (q *Stmt) String() string {
+	if q.sql == "" {
+		q.applyBuildHook()
+		q.sql = q.build()
+	}
+	return q.sql
+}
+
+// applyBuildHook calls a function set by Dialect.OnBuild
+// unless it was called for the statement already.
+func (q *Stmt) applyBuildHook() {
+	if q.hooked {
+		return
 	}
-	q.buf.WriteString(query.String())
-	q.buf.WriteString(suffix)
-	chunk.bufHigh = q.buf.Len()
-	// Close the subquery
-	query.Close()
+	if fn := q.dialect.buildHook(); fn != nil {
+		q.hooked = true
+		fn(q)
+	}
+}
 
-	return q
+// pendingHook reports if a function set by Dialect.OnBuild
+// is yet to be called for the statement.
+func (q *Stmt) pendingHook() bool {
+	return !q.hooked && q.dialect.buildHook() != nil
 }
 
 /*
-Union adds a UNION clause to the statement.
+Peek returns an SQL statement and a copy of its arguments
+without storing the built statement into Stmt.
 
-all argument controls if UNION ALL or UNION clause
-is to be constructed. Use UNION ALL if possible to
-get faster queries.
+It's meant to inspect a statement that is going to be modified further,
+for example in tests. Returned values remain valid after Close.
 */
-func (q *Stmt) Union(all bool, query *Stmt) *Stmt {
-	p := posUnion
-	if len(q.chunks) > 0 {
-		last := (&q.chunks[len(q.chunks)-1]).pos
-		if last >= p {
-			p = last + 1
-		}
-	}
-	var index int
-	if all {
-		index = q.addChunk(p, "UNION ALL ", "", query.args, "")
-	} else {
-		index = q.addChunk(p, "UNION ", "", query.args, "")
+func (q *Stmt) Peek() (sql string, args []interface{}) {
+	if q.pendingHook() {
+		c := q.Clone()
+		c.applyBuildHook()
+		sql, args = c.Peek()
+		c.Close()
+		return sql, args
 	}
-	chunk := &q.chunks[index]
-	// Make sure subquery is not dialect-specific.
-	if query.dialect != NoDialect {
-		query.dialect = NoDialect
-		query.Invalidate()
+	sql = q.sql
+	if sql == "" {
+		sql = q.build()
 	}
-	q.buf.WriteString(query.String())
-	chunk.bufHigh = q.buf.Len()
-	// Close the subquery
-	query.Close()
-
-	return q
+	return sql, q.ArgsCopy()
 }
 
 /*
-Clause appends a raw SQL fragment to the statement.
-
-Use it to add a raw SQL fragment like ON CONFLICT, ON DUPLICATE KEY, WINDOW, etc.
+WriteTo writes an SQL statement to w.
 
-An SQL fragment added via Clause method appears after the last clause previously
-added. If called first, Clause method prepends a statement with a raw SQL.
+Unlike String, WriteTo doesn't allocate a string for a statement
+that is not cached yet. Use it to assemble a batch of statements
+in a single buffer.
 */
-func (q *Stmt) Clause(expr string, args ...interface{}) *Stmt {
-	p := posStart
-	if len(q.chunks) > 0 {
-		p = (&q.chunks[len(q.chunks)-1]).pos + 10
+func (q *Stmt) WriteTo(w io.Writer) (int64, error) {
+	sql := q.sql
+	if sql == "" {
+		q.applyBuildHook()
+		sql, _ = q.dialect.getCachedSQL(q.buf)
 	}
-	q.addChunk(p, expr, "", args, ", ")
-	return q
+	if sql != "" {
+		n, err := io.WriteString(w, sql)
+		return int64(n), err
+	}
+	buf := getBuffer()
+	q.render(buf)
+	n, err := w.Write(buf.B)
+	putBuffer(buf)
+	return int64(n), err
 }
 
-// String method builds and returns an SQL statement.
-func (q *Stmt) String() string {
-	if q.sql == "" {
-		// Calculate the buffer hash and check for available queries
-		sql, ok := q.dialect.getCachedSQL(q.buf)
-		if ok {
-			q.sql = sql
-		} else {
-			// Build a query
-			var argNo int = 1
-			buf := strings.Builder{}
-
-			pos := chunkPos(0)
-			for n, chunk := range q.chunks {
-				// Separate clauses with spaces
-				if n > 0 && chunk.pos > pos {
-					buf.Write(space)
-				}
-				s := q.buf.B[chunk.bufLow:chunk.bufHigh]
-				if chunk.argLen > 0 && q.dialect == PostgreSQL {
-					argNo, _ = writePg(argNo, s, &buf)
-				} else {
-					buf.Write(s)
-				}
-				pos = chunk.pos
-			}
-			q.sql = buf.String()
-			// Save it for reuse
-			q.dialect.putCachedSQL(q.buf, q.sql)
+// build renders an SQL statement or fetches it from the dialect cache.
+func (q *Stmt) build() string {
+	// Calculate the buffer hash and check for available queries
+	sql, ok := q.dialect.getCachedSQL(q.buf)
+	if ok {
+		return sql
+	}
+	// Build a query
+	buf := strings.Builder{}
+	q.render(&buf)
+	sql = buf.String()
+	// Save it for reuse
+	q.dialect.putCachedSQL(q.buf, sql)
+	return sql
+}
+
+// render writes an SQL statement to buf.
+func (q *Stmt) render(buf sqlWriter) {
+	var argNo int = 1
+	pos := chunkPos(0)
+	for n, chunk := range q.chunks {
+		// Separate clauses with spaces
+		if n > 0 && chunk.pos > pos {
+			buf.Write(space)
 		}
+		argNo = q.writeChunk(argNo, chunk, buf)
+		pos = chunk.pos
 	}
-	return q.sql
+}
+
+// writeChunk writes a chunk to buf replacing placeholders the way
+// a dialect requires. argNo is a number of the first chunk argument.
+// It returns a number of an argument next to chunk arguments.
+func (q *Stmt) writeChunk(argNo int, chunk stmtChunk, buf sqlWriter) int {
+	s := q.buf.B[chunk.bufLow:chunk.bufHigh]
+	switch {
+	case chunk.argLen == 0 && !chunk.escaped:
+		buf.Write(s)
+	case q.dialect == PostgreSQL:
+		argNo, _ = writePg(argNo, s, buf)
+	case q.dialect == SQLite && bytes.Contains(s, []byte(backRef)):
+		argNo = writeSQLite(argNo, s, buf)
+	case chunk.escaped && q.dialect != subQuery:
+		writeUnescaped(s, buf)
+		argNo += chunk.argLen
+	default:
+		buf.Write(s)
+		argNo += chunk.argLen
+	}
+	return argNo
 }
 
 /*
@@ -611,6 +2428,213 @@ func (q *Stmt) Dest() []interface{} {
 	return q.dest
 }
 
+/*
+ArgCount returns the number of statement arguments.
+
+Unlike Args, it doesn't expose the argument list, and the result
+doesn't depend on whether the statement has been built.
+*/
+func (q *Stmt) ArgCount() int {
+	return len(q.args)
+}
+
+/*
+ArgsCopy returns a copy of the argument list.
+
+Unlike Args, a returned slice is safe to use after Stmt is closed.
+*/
+func (q *Stmt) ArgsCopy() []interface{} {
+	args := make([]interface{}, len(q.args))
+	copy(args, q.args)
+	return args
+}
+
+/*
+MapArgs replaces every statement argument with a value returned by fn.
+
+Use it to convert arguments to driver-specific types right before
+a statement is executed:
+
+	q.MapArgs(func(i int, v interface{}) interface{} {
+		if ids, ok := v.([]int64); ok {
+			return pq.Array(ids)
+		}
+		return v
+	})
+
+Arguments added after a MapArgs call are not converted.
+*/
+func (q *Stmt) MapArgs(fn func(i int, v interface{}) interface{}) *Stmt {
+	for i, v := range q.args {
+		q.args[i] = fn(i, v)
+	}
+	return q
+}
+
+/*
+Query is a built SQL statement along with its arguments.
+
+It's detached from a Stmt it was made of and remains valid after Close.
+Pass it to ExecQuery to execute it later.
+*/
+type Query struct {
+	SQL  string
+	Args []interface{}
+}
+
+/*
+Snapshot builds a statement and returns it along with a copy of its arguments:
+
+	q := sqlf.Update("users").Set("is_active", false).Where("id = ?", id)
+	job := q.Snapshot()
+	q.Close()
+	// ...
+	_, err := sqlf.ExecQuery(ctx, db, job)
+
+Argument values are copied, but values they refer to, like pointers,
+are shared. Byte slices are copied as well.
+*/
+func (q *Stmt) Snapshot() Query {
+	sql := q.String()
+	args := q.ArgsCopy()
+	for i, arg := range args {
+		if b, ok := arg.([]byte); ok {
+			args[i] = append([]byte(nil), b...)
+		}
+	}
+	return Query{SQL: sql, Args: args}
+}
+
+/*
+DestCopy returns a copy of the list of value pointers passed via To method calls.
+
+Unlike Dest, a returned slice is safe to use after Stmt is closed.
+*/
+func (q *Stmt) DestCopy() []interface{} {
+	dest := make([]interface{}, len(q.dest))
+	copy(dest, q.dest)
+	return dest
+}
+
+/*
+BuildOnce builds an SQL statement and returns it along with a copy
+of the argument list.
+
+Returned values do not depend on Stmt and remain valid after Close,
+so they can be cached and shared between goroutines.
+
+Once BuildOnce is called, String and Args methods only read the statement.
+It's safe to call them concurrently as long as no goroutine modifies
+or closes the statement.
+*/
+func (q *Stmt) BuildOnce() (sql string, args []interface{}) {
+	return q.String(), q.ArgsCopy()
+}
+
+/*
+SelectColumnCount returns the number of expressions in a SELECT clause.
+
+Commas within parentheses and quotes don't separate expressions,
+so function calls and subqueries are counted as a single column:
+
+	q := sqlf.From("orders").
+		Select("id, COALESCE(amount, 0)").
+		Select("(SELECT MAX(ts) FROM events WHERE order_id = orders.id)")
+	q.SelectColumnCount() // 3
+
+Compare the result with len(q.Dest()) to catch binding mistakes.
+*/
+func (q *Stmt) SelectColumnCount() int {
+	text := strings.TrimPrefix(q.chunkText(posSelect), "SELECT")
+	return splitTuples(text)[0]
+}
+
+/*
+Validate checks a statement for common mistakes.
+
+Validate reports an error if the number of values of an INSERT
+statement row doesn't match the number of columns.
+It also returns an error recorded by builder methods, see Err,
+and TooManyArgsError if a PostgreSQL statement has more than
+MaxPostgreSQLArgs arguments.
+*/
+func (q *Stmt) Validate() error {
+	if err := q.check(); err != nil {
+		return err
+	}
+	if q.findChunk(posInsertFields) < 0 {
+		return nil
+	}
+	columns := splitTuples(q.chunkText(posInsertFields))[0]
+	for row, values := range splitTuples(q.chunkText(posValues)) {
+		if values != columns {
+			return fmt.Errorf("sqlf: INSERT row %d has %d value(s) for %d column(s)", row+1, values, columns)
+		}
+	}
+	return nil
+}
+
+// check returns an error recorded while building a statement
+// or TooManyArgsError if a statement has more arguments
+// than a database accepts.
+func (q *Stmt) check() error {
+	if q.err != nil {
+		return q.err
+	}
+	if q.dialect == PostgreSQL && len(q.args) > MaxPostgreSQLArgs {
+		return &TooManyArgsError{Args: len(q.args), Limit: MaxPostgreSQLArgs}
+	}
+	return nil
+}
+
+// chunkText returns the text of all chunks at a given position.
+func (q *Stmt) chunkText(pos chunkPos) string {
+	var buf strings.Builder
+	for _, chunk := range q.chunks {
+		if chunk.pos == pos {
+			buf.Write(q.buf.B[chunk.bufLow:chunk.bufHigh])
+		}
+	}
+	return buf.String()
+}
+
+// chunkArgs returns a copy of arguments of all chunks at a given position
+// and reports if any of those chunks contains escaped question marks.
+func (q *Stmt) chunkArgs(pos chunkPos) (args []interface{}, escaped bool) {
+	argNo := 0
+	for _, chunk := range q.chunks {
+		if chunk.pos == pos {
+			args = append(args, q.args[argNo:argNo+chunk.argLen]...)
+			escaped = escaped || chunk.escaped
+		}
+		argNo += chunk.argLen
+	}
+	return args, escaped
+}
+
+/*
+Err returns the first error recorded by builder methods.
+
+Builder methods don't panic on misuse, like a Set call for a SELECT
+statement. They record an error instead, and Query, QueryRow, Exec
+and other executor methods return it without running a statement:
+
+	q := sqlf.From("users").Set("name", name)
+	_, err := q.Exec(ctx, db) // sqlf: Set is called for a statement with no INSERT or UPDATE clause
+
+An error of a subquery is passed to the enclosing statement.
+*/
+func (q *Stmt) Err() error {
+	return q.err
+}
+
+// setErr records err unless an error is recorded already.
+func (q *Stmt) setErr(err error) {
+	if q.err == nil {
+		q.err = err
+	}
+}
+
 /*
 Invalidate forces a rebuild on next query execution.
 
@@ -632,7 +2656,27 @@ func (q *Stmt) Close() {
 	reuseStmt(q)
 }
 
+/*
+Grow makes sure a statement can get n more clauses or expressions
+without reallocating its internal list of SQL fragments.
+
+Consecutive calls adding expressions to the same clause share a fragment,
+so Grow is only worth calling for statements that interleave clauses a lot.
+The statement text buffer is pooled and doesn't need to be grown.
+*/
+func (q *Stmt) Grow(n int) *Stmt {
+	if n > 0 && cap(q.chunks)-len(q.chunks) < n {
+		chunks := make(stmtChunks, len(q.chunks), len(q.chunks)+n)
+		copy(chunks, q.chunks)
+		q.chunks = chunks
+	}
+	return q
+}
+
 // Clone creates a copy of the statement.
+//
+// A clone reuses the SQL built for the original statement, or the cached one
+// if the original statement wasn't built yet, until either of them is modified.
 func (q *Stmt) Clone() *Stmt {
 	stmt := getStmt(q.dialect)
 	if cap(stmt.chunks) < len(q.chunks) {
@@ -643,6 +2687,10 @@ func (q *Stmt) Clone() *Stmt {
 	}
 	stmt.args = insertAt(stmt.args, q.args, 0)
 	stmt.dest = insertAt(stmt.dest, q.dest, 0)
+	stmt.destStruct = q.destStruct
+	stmt.nullAsZero = q.nullAsZero
+	stmt.hooked = q.hooked
+	stmt.err = q.err
 	stmt.buf.Write(q.buf.B)
 	stmt.sql = q.sql
 
@@ -656,6 +2704,100 @@ func (q *Stmt) Clone() *Stmt {
 //
 // Note: this method does no type checks and returns no errors.
 func (q *Stmt) Bind(data interface{}) *Stmt {
+	q.bindFields(data, q.Select)
+	return q
+}
+
+/*
+BindQualified works like Bind, but qualifies selected columns
+with a table name or an alias:
+
+	q := sqlf.From("orders o").
+		BindQualified("o", &order).
+		Join("users u", "u.id = o.user_id").
+		Where("u.email = ?", email)
+
+produces
+
+	SELECT o.id, o.user_id, o.amount FROM orders o JOIN users u ON (u.id = o.user_id) WHERE u.email = ?
+*/
+func (q *Stmt) BindQualified(table string, data interface{}) *Stmt {
+	walkFields(data, func(name string, ptr interface{}) {
+		q.Select(table + "." + name).To(ptr)
+	})
+	return q
+}
+
+/*
+NullAsZero makes Query and QueryRow methods set scan targets bound
+via To, Bind and similar methods to zero values when a column is NULL:
+
+	var email string
+	err := sqlf.From("users").
+		Select("email").To(&email).
+		Where("id = ?", id).
+		NullAsZero().
+		QueryRowAndClose(ctx, db)
+
+Without it, scanning NULL into a non-pointer field like a string fails.
+Non-NULL values are converted the way Scan does it, so out of range
+values and the like are still reported as errors.
+*/
+func (q *Stmt) NullAsZero() *Stmt {
+	q.nullAsZero = true
+	return q
+}
+
+/*
+ReturningStruct adds structure fields to RETURNING clause of a statement.
+Structure fields have to be annotated with "db" tag.
+
+	var u User
+	err := sqlf.InsertInto("users").
+		Set("name", name).
+		ReturningStruct(&u).
+		QueryRowAndClose(ctx, db)
+
+Works the same way as Bind, but for INSERT, UPDATE and DELETE statements.
+*/
+func (q *Stmt) ReturningStruct(data interface{}) *Stmt {
+	q.bindFields(data, func(expr string, args ...interface{}) *Stmt {
+		return q.Returning(expr)
+	})
+	return q
+}
+
+/*
+ReturningAll adds a RETURNING * clause to a statement and scans
+returned columns to fields of a structure by name:
+
+	var u User
+	err := sqlf.InsertInto("users").
+		Set("name", name).
+		ReturningAll(&u).
+		QueryRowAndClose(ctx, db)
+
+Structure fields have to be annotated with "db" tag.
+Returned columns with no matching fields are skipped.
+
+Scan targets bound via To method calls are ignored.
+*/
+func (q *Stmt) ReturningAll(data interface{}) *Stmt {
+	q.destStruct = data
+	return q.Returning("*")
+}
+
+// bindFields adds db-tagged structure fields to a clause and binds
+// them as scan targets.
+func (q *Stmt) bindFields(data interface{}, clause func(expr string, args ...interface{}) *Stmt) {
+	walkFields(data, func(name string, ptr interface{}) {
+		clause(name).To(ptr)
+	})
+}
+
+// walkFields calls fn for every db-tagged field of a structure data points to,
+// including fields of embedded structures.
+func walkFields(data interface{}, fn func(name string, ptr interface{})) {
 	typ := reflect.TypeOf(data).Elem()
 	val := reflect.ValueOf(data).Elem()
 
@@ -663,19 +2805,110 @@ func (q *Stmt) Bind(data interface{}) *Stmt {
 		field := val.Field(i)
 		t := typ.Field(i)
 		if field.Kind() == reflect.Struct && t.Anonymous {
-			q.Bind(field.Addr().Interface())
+			walkFields(field.Addr().Interface(), fn)
 		} else {
 			dbFieldName := t.Tag.Get("db")
 			if dbFieldName != "" {
-				q.Select(dbFieldName).To(field.Addr().Interface())
+				fn(dbFieldName, field.Addr().Interface())
 			}
 		}
 	}
-	return q
+}
+
+// writeSubQuery writes a subquery to the statement buffer as a part
+// of a chunk with a given index and closes the subquery.
+func (q *Stmt) writeSubQuery(index int, query *Stmt) {
+	q.setErr(query.err)
+	for _, c := range query.chunks {
+		if c.escaped {
+			q.chunks[index].escaped = true
+			break
+		}
+	}
+	// Make sure subquery is not dialect-specific.
+	query.applyBuildHook()
+	if query.dialect != subQuery {
+		query.dialect = subQuery
+		query.Invalidate()
+	}
+	sql := query.String()
+	if !q.dialect.numbered() && strings.Contains(sql, backRef) {
+		q.setErr(errors.New("sqlf: a subquery with ?N placeholders is built for another dialect"))
+	}
+	q.buf.WriteString(sql)
+	// Close the subquery
+	query.Close()
+}
+
+// replaceVerb replaces a leading keyword of a clause.
+func (q *Stmt) replaceVerb(pos chunkPos, verb, newVerb string) {
+	text := q.chunkText(pos)
+	if !strings.HasPrefix(text, verb) {
+		return
+	}
+	args, escaped := q.chunkArgs(pos)
+	q.removeChunks(pos, pos)
+	i := q.addChunk(pos, newVerb, strings.TrimPrefix(text[len(verb):], " "), args, ", ")
+	q.chunks[i].escaped = escaped
+}
+
+// findChunk returns an index of the first chunk at a given position or -1.
+func (q *Stmt) findChunk(pos chunkPos) int {
+	for i := range q.chunks {
+		if q.chunks[i].pos == pos {
+			return i
+		}
+	}
+	return -1
+}
+
+// removeChunks removes chunks positioned between low and high
+// along with their arguments and text.
+func (q *Stmt) removeChunks(low, high chunkPos) {
+	var removed stmtChunks
+	n, argNo := 0, 0
+	for _, chunk := range q.chunks {
+		if chunk.pos >= low && chunk.pos <= high {
+			if chunk.argLen > 0 {
+				copy(q.args[argNo:], q.args[argNo+chunk.argLen:])
+				for i := len(q.args) - chunk.argLen; i < len(q.args); i++ {
+					q.args[i] = nil
+				}
+				q.args = q.args[:len(q.args)-chunk.argLen]
+			}
+			removed = append(removed, chunk)
+			continue
+		}
+		argNo += chunk.argLen
+		q.chunks[n] = chunk
+		n++
+	}
+	q.chunks = q.chunks[:n]
+
+	// Cut removed text out of the buffer, as the buffer is used
+	// as a dialect cache key. Start from the end of the buffer
+	// to keep offsets of the text yet to be cut valid.
+	sort.Slice(removed, func(i, j int) bool {
+		return removed[i].bufLow > removed[j].bufLow
+	})
+	for _, chunk := range removed {
+		l := chunk.bufHigh - chunk.bufLow
+		q.buf.B = append(q.buf.B[:chunk.bufLow], q.buf.B[chunk.bufHigh:]...)
+		for i := range q.chunks {
+			if q.chunks[i].bufLow >= chunk.bufHigh {
+				q.chunks[i].bufLow -= l
+				q.chunks[i].bufHigh -= l
+			}
+		}
+	}
+	q.Invalidate()
 }
 
 // join adds a join clause to a SELECT statement
 func (q *Stmt) join(joinType, table, on string) (index int) {
+	if q.dialect == MySQL {
+		q.multiTableDelete()
+	}
 	buf := bytebufferpool.Get()
 	buf.WriteString(joinType)
 	buf.WriteString(table)
@@ -690,6 +2923,46 @@ func (q *Stmt) join(joinType, table, on string) (index int) {
 	return index
 }
 
+// multiTableDelete turns DELETE FROM table into a MySQL
+// multi-table DELETE table FROM table statement.
+func (q *Stmt) multiTableDelete() {
+	const verb = "DELETE FROM"
+	text := q.chunkText(posDelete)
+	if !strings.HasPrefix(text, verb+" ") {
+		return
+	}
+	// Delete rows from a table alias if there is one
+	fields := strings.Fields(text[len(verb):])
+	if len(fields) == 0 {
+		return
+	}
+	q.replaceVerb(posDelete, verb, "DELETE "+fields[len(fields)-1]+" FROM")
+}
+
+// apply adds an APPLY clause to a SELECT statement
+func (q *Stmt) apply(applyType string, query *Stmt, alias string) {
+	index := q.addChunk(posFrom, "", applyType, query.args, " ")
+	chunk := &q.chunks[index]
+	q.writeSubQuery(index, query)
+	q.buf.WriteString(") ")
+	q.buf.WriteString(alias)
+	chunk.bufHigh = q.buf.Len()
+}
+
+// indexArgs replaces ?N placeholders of expr, see expandIndexed.
+// PostgreSQL and SQLite statements get an argument once
+// no matter how many placeholders refer to it.
+func (q *Stmt) indexArgs(expr string, args []interface{}) (string, []interface{}) {
+	if len(args) == 0 || !hasIndexedPlaceholders(expr) {
+		return expr, args
+	}
+	expr, args, err := expandIndexed(expr, args, q.dialect.numbered())
+	if err != nil {
+		q.setErr(err)
+	}
+	return expr, args
+}
+
 // addChunk adds a clause or expression to a statement.
 func (q *Stmt) addChunk(pos chunkPos, clause, expr string, args []interface{}, sep string) (index int) {
 	// Remember the position
@@ -788,6 +3061,53 @@ loop:
 	return index
 }
 
+/*
+SetStructs adds a row to a bulk INSERT statement for every element
+of a slice of structures:
+
+	users := []User{{Name: "Ann", Email: "ann@example.com"}, {Name: "Bob", Email: "bob@example.com"}}
+	q := sqlf.InsertInto("users").SetStructs(users)
+
+produces
+
+	INSERT INTO users ( name, email ) VALUES ( ?, ? ), ( ?, ? )
+
+Structure fields have to be annotated with "db" tag the way Bind
+requires. Slice elements can be structures or pointers to structures.
+SetStructs records an error if rows is not a slice or its elements
+are of different types, see Err.
+*/
+func (q *Stmt) SetStructs(rows interface{}) *Stmt {
+	v := reflect.ValueOf(rows)
+	if v.Kind() != reflect.Slice {
+		q.setErr(errors.New("sqlf: SetStructs expects a slice"))
+		return q
+	}
+	var typ reflect.Type
+	for i := 0; i < v.Len(); i++ {
+		elem := v.Index(i)
+		if elem.Kind() == reflect.Interface {
+			elem = elem.Elem()
+		}
+		if i == 0 {
+			typ = elem.Type()
+		} else if elem.Type() != typ {
+			q.setErr(fmt.Errorf("sqlf: SetStructs expects elements of type %s, got %s", typ, elem.Type()))
+			return q
+		}
+		if elem.Kind() != reflect.Ptr {
+			ptr := reflect.New(elem.Type())
+			ptr.Elem().Set(elem)
+			elem = ptr
+		}
+		row := q.NewRow()
+		walkFields(elem.Interface(), func(name string, ptr interface{}) {
+			row = row.Set(name, reflect.ValueOf(ptr).Elem().Interface())
+		})
+	}
+	return q
+}
+
 /*
 NewRow method helps to construct a bulk INSERT statement.
 
@@ -860,6 +3180,7 @@ SetExpr is an extended version of Set method.
 */
 func (row newRow) SetExpr(field, expr string, args ...interface{}) newRow {
 	q := row.Stmt
+	expr, args = q.indexArgs(expr, args)
 
 	if row.first {
 		q.addChunk(posInsertFields, "", field, nil, ", ")
@@ -879,13 +3200,35 @@ func (row newRow) SetExpr(field, expr string, args ...interface{}) newRow {
 	}
 }
 
+// clauseInfo describes a clause that can be referenced by its name.
+type clauseInfo struct {
+	name string
+	pos  chunkPos
+	sep  string
+}
+
+var clauses = map[string]clauseInfo{
+	"SELECT":    {"SELECT", posSelect, ", "},
+	"FROM":      {"FROM", posFrom, ", "},
+	"WHERE":     {"WHERE", posWhere, " AND "},
+	"GROUP BY":  {"GROUP BY", posGroupBy, ", "},
+	"HAVING":    {"HAVING", posHaving, " AND "},
+	"ORDER BY":  {"ORDER BY", posOrderBy, ", "},
+	"SET":       {"SET", posSet, ", "},
+	"RETURNING": {"RETURNING", posReturning, ", "},
+}
+
 var (
-	space            = []byte{' '}
-	placeholder      = []byte{'?'}
-	placeholderComma = []byte{'?', ','}
-	joinOn           = []byte{' ', 'O', 'N', ' ', '('}
+	space  = []byte{' '}
+	joinOn = []byte{' ', 'O', 'N', ' ', '('}
 )
 
+// maxInPlaceholders is a number of placeholders In method copies at once.
+const maxInPlaceholders = 128
+
+// inPlaceholders is a precomputed ?,?,? list for In method.
+var inPlaceholders = strings.Repeat("?,", maxInPlaceholders)
+
 type chunkPos int
 
 const (
@@ -1,7 +1,13 @@
 package sqlf_test
 
 import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
 	"fmt"
+	"strings"
+	"sync"
 	"testing"
 	"time"
 
@@ -164,6 +170,124 @@ func TestWithRecursive(t *testing.T) {
 	require.Equal(t, "WITH RECURSIVE regional_sales AS (SELECT region, SUM(amount) AS total_sales FROM orders GROUP BY region), top_regions AS (SELECT region FROM regional_sales ORDER BY total_sales DESC LIMIT ?) SELECT region, product, SUM(quantity) AS product_units, SUM(amount) AS product_sales FROM orders WHERE region IN (SELECT region FROM top_regions) GROUP BY region, product", q.String())
 }
 
+func TestWithRecursiveTerms(t *testing.T) {
+	q := sqlf.PostgreSQL.From("subordinates").
+		With("managers", sqlf.From("employees").Select("id").Where("level > ?", 2)).
+		WithRecursive("subordinates",
+			sqlf.From("employees").Select("id, manager_id").Where("id = ?", 1),
+			sqlf.From("employees e").
+				Select("e.id, e.manager_id").
+				Join("subordinates s", "e.manager_id = s.id").
+				Where("e.is_active = ?", true),
+			true).
+		Select("id").
+		Where("id <> ?", 1)
+	defer q.Close()
+	require.Equal(t, "WITH RECURSIVE managers AS (SELECT id FROM employees WHERE level > $1), subordinates AS (SELECT id, manager_id FROM employees WHERE id = $2 UNION ALL SELECT e.id, e.manager_id FROM employees e JOIN subordinates s ON (e.manager_id = s.id) WHERE e.is_active = $3) SELECT id FROM subordinates WHERE id <> $4", q.String())
+	require.Equal(t, []interface{}{2, 1, true, 1}, q.Args())
+
+	q2 := sqlf.WithRecursive("t(n)",
+		sqlf.New("VALUES (?)", 1),
+		sqlf.From("t").Select("n + 1").Where("n < ?", 100),
+		false).
+		From("t").
+		Select("SUM(n)")
+	defer q2.Close()
+	require.Equal(t, "WITH RECURSIVE t(n) AS (VALUES (?) UNION SELECT n + 1 FROM t WHERE n < ?) SELECT SUM(n) FROM t", q2.String())
+	require.Equal(t, []interface{}{1, 100}, q2.Args())
+}
+
+func TestWithMaterialized(t *testing.T) {
+	q := sqlf.PostgreSQL.From("totals").
+		WithMaterialized("totals", true, sqlf.From("orders").Select("SUM(amount) AS total").Where("region = ?", "eu")).
+		WithMaterialized("counts", false, sqlf.From("orders").Select("COUNT(*)")).
+		Select("total")
+	defer q.Close()
+	require.Equal(t, "WITH totals AS MATERIALIZED (SELECT SUM(amount) AS total FROM orders WHERE region = $1), counts AS NOT MATERIALIZED (SELECT COUNT(*) FROM orders) SELECT total FROM totals", q.String())
+
+	q2 := sqlf.MySQL.From("totals").
+		WithMaterialized("totals", true, sqlf.From("orders").Select("SUM(amount) AS total")).
+		Select("total")
+	defer q2.Close()
+	require.Equal(t, "WITH totals AS (SELECT SUM(amount) AS total FROM orders) SELECT total FROM totals", q2.String())
+}
+
+func TestWithColumns(t *testing.T) {
+	q := sqlf.PostgreSQL.From("t").
+		WithColumns("RECURSIVE t", []string{"n"},
+			sqlf.New("VALUES (1)").
+				Union(true, sqlf.From("t").Select("n + 1").Where("n < ?", 100))).
+		Select("SUM(n)").
+		Where("n > ?", 10)
+	defer q.Close()
+
+	require.Equal(t, "WITH RECURSIVE t(n) AS (VALUES (1) UNION ALL SELECT n + 1 FROM t WHERE n < $1) SELECT SUM(n) FROM t WHERE n > $2", q.String())
+	require.Equal(t, []interface{}{100, 10}, q.Args())
+}
+
+func TestSetLimitOffset(t *testing.T) {
+	q := sqlf.PostgreSQL.From("orders").Select("id").Where("user_id = ?", 42).OrderBy("id").SetLimitOffset(10, 0)
+	defer q.Close()
+	sql := q.String()
+	require.Equal(t, "SELECT id FROM orders WHERE user_id = $1 ORDER BY id LIMIT $2 OFFSET $3", sql)
+	require.Equal(t, []interface{}{42, 10, 0}, q.Args())
+
+	q2 := q.Clone()
+	defer q2.Close()
+	allocs := testing.AllocsPerRun(10, func() {
+		q2.SetLimitOffset(10, 20)
+		if q2.String() != sql {
+			t.Fail()
+		}
+	})
+	require.Equal(t, []interface{}{42, 10, 20}, q2.Args())
+	if !raceEnabled {
+		// Boxing of the new argument values is the only allocation
+		require.LessOrEqual(t, allocs, float64(2))
+	}
+}
+
+func TestCloneConcurrent(t *testing.T) {
+	q := sqlf.PostgreSQL.From("orders").
+		Select("id, amount").
+		Where("user_id = ?", 42).
+		OrderBy("id")
+	defer q.Close()
+	base := q.String()
+
+	const pages = 8
+	clones := make([]*sqlf.Stmt, pages)
+	for i := range clones {
+		clones[i] = q.Clone()
+	}
+
+	var wg sync.WaitGroup
+	wg.Add(pages + 1)
+	go func() {
+		defer wg.Done()
+		q.Where("status = ?", "new")
+		for i := 0; i < 20; i++ {
+			q.Select(fmt.Sprintf("extra_%d", i))
+		}
+		_ = q.String()
+	}()
+	for i, c := range clones {
+		go func(page int, c *sqlf.Stmt) {
+			defer wg.Done()
+			c.Where("amount > ?", page).Limit(10).Offset(page * 10)
+			require.Equal(t, "SELECT id, amount FROM orders WHERE user_id = $1 AND amount > $2 ORDER BY id LIMIT $3 OFFSET $4", c.String())
+			require.Equal(t, []interface{}{42, page, 10, page * 10}, c.Args())
+		}(i, c)
+	}
+	wg.Wait()
+
+	for _, c := range clones {
+		c.Close()
+	}
+	require.NotEqual(t, base, q.String())
+	require.Equal(t, []interface{}{42, "new"}, q.Args())
+}
+
 func TestSubQueryDialect(t *testing.T) {
 	q := sqlf.PostgreSQL.From("users u").
 		Select("email").
@@ -180,6 +304,78 @@ func TestSubQueryDialect(t *testing.T) {
 	require.Equal(t, []interface{}{"2019-01-01", 100}, q.Args())
 }
 
+func TestSubQueryPreRendered(t *testing.T) {
+	sq := sqlf.PostgreSQL.From("orders").
+		Select("id").
+		Where("user_id = u.id").
+		Where("amount > ?", 100).
+		WhereRaw("tags ? 'gift'")
+	require.Equal(t, "SELECT id FROM orders WHERE user_id = u.id AND amount > $1 AND tags ? 'gift'", sq.String())
+
+	q := sqlf.PostgreSQL.From("users u").
+		Select("email").
+		Where("registered > ?", "2019-01-01").
+		SubQuery("EXISTS (", ")", sq).
+		Where("is_active = ?", true)
+	defer q.Close()
+
+	require.Equal(t, "SELECT email FROM users u WHERE registered > $1 AND EXISTS (SELECT id FROM orders WHERE user_id = u.id AND amount > $2 AND tags ? 'gift') AND is_active = $3", q.String())
+	require.Equal(t, []interface{}{"2019-01-01", 100, true}, q.Args())
+}
+
+func TestIndexedPlaceholders(t *testing.T) {
+	q := sqlf.PostgreSQL.From("events").
+		Select("id").
+		Where("kind = ?", "meeting").
+		Where("starts_at <= ?1 AND ends_at >= ?1", 100)
+	defer q.Close()
+	require.Equal(t, "SELECT id FROM events WHERE kind = $1 AND starts_at <= $2 AND ends_at >= $2", q.String())
+	require.Equal(t, []interface{}{"meeting", 100}, q.Args())
+	require.Equal(t, "SELECT id FROM events WHERE kind = 'meeting' AND starts_at <= 100 AND ends_at >= 100", q.DebugSQL())
+
+	q2 := sqlf.SQLite.From("events").
+		Select("id").
+		Where("starts_at <= ?1 AND ends_at >= ?1", 100).
+		Where("kind = ?", "meeting")
+	defer q2.Close()
+	require.Equal(t, "SELECT id FROM events WHERE starts_at <= ? AND ends_at >= ?1 AND kind = ?", q2.String())
+	require.Equal(t, []interface{}{100, "meeting"}, q2.Args())
+
+	q3 := sqlf.MySQL.From("events").
+		Select("id").
+		Where("starts_at <= ?1 AND ends_at >= ?1", 100)
+	defer q3.Close()
+	require.Equal(t, "SELECT id FROM events WHERE starts_at <= ? AND ends_at >= ?", q3.String())
+	require.Equal(t, []interface{}{100, 100}, q3.Args())
+
+	q4 := sqlf.PostgreSQL.From("events").
+		Select("id").
+		Where("kind = ?", "meeting").
+		SubQuery("id IN (", ")", sqlf.PostgreSQL.From("slots").Select("event_id").Where("a = ?1 OR b = ?1", 5))
+	defer q4.Close()
+	require.Equal(t, "SELECT id FROM events WHERE kind = $1 AND id IN (SELECT event_id FROM slots WHERE a = $2 OR b = $2)", q4.String())
+	require.Equal(t, []interface{}{"meeting", 5}, q4.Args())
+
+	q5 := sqlf.PostgreSQL.From("events").Where("starts_at <= ?2", 100)
+	defer q5.Close()
+	require.Error(t, q5.Err())
+
+	q6 := sqlf.PostgreSQL.From("generate_series(?1, ?1 + 10) AS n", 5).
+		Select("n").
+		ExprSep(" || ", "?1 || ?1", "x").
+		AppendTo("WHERE", "n BETWEEN ?1 AND ?1 + 1", 7).
+		LimitExpr("?1 * ?1", 3).
+		Clause("OFFSET ?1 - ?1", 1)
+	defer q6.Close()
+	require.Equal(t, "SELECT n || $1 || $1 FROM generate_series($2, $2 + 10) AS n WHERE n BETWEEN $3 AND $3 + 1 LIMIT $4 * $4 OFFSET $5 - $5", q6.String())
+	require.Equal(t, []interface{}{"x", 5, 7, 3, 1}, q6.Args())
+
+	q7 := sqlf.MySQL.New("CALL p(?1, ?1)", 1)
+	defer q7.Close()
+	require.Equal(t, "CALL p(?, ?)", q7.String())
+	require.Equal(t, []interface{}{1, 1}, q7.Args())
+}
+
 func TestClone(t *testing.T) {
 	var (
 		value  string
@@ -261,6 +457,196 @@ func TestUnion(t *testing.T) {
 	require.Equal(t, "SELECT id, status FROM tasks WHERE status = ? UNION SELECT id, status FROM tasks WHERE status = ?", q.String())
 }
 
+func TestUnionOrderByLimit(t *testing.T) {
+	q := sqlf.PostgreSQL.From("tasks").
+		Select("id, status").
+		Where("status = ?", "new").
+		Union(true, sqlf.PostgreSQL.From("tasks").
+			Select("id, status").
+			Where("status = ?", "wip")).
+		Union(true, sqlf.PostgreSQL.From("archived_tasks").
+			Select("id, status").
+			Where("status = ?", "done")).
+		OrderBy("id DESC").
+		Limit(10).
+		Offset(20)
+	defer q.Close()
+	require.Equal(t, "SELECT id, status FROM tasks WHERE status = $1 UNION ALL SELECT id, status FROM tasks WHERE status = $2 UNION ALL SELECT id, status FROM archived_tasks WHERE status = $3 ORDER BY id DESC LIMIT $4 OFFSET $5", q.String())
+	require.Equal(t, []interface{}{"new", "wip", "done", 10, 20}, q.Args())
+
+	// ORDER BY added before a union applies to the combined result as well
+	q2 := sqlf.PostgreSQL.From("tasks").
+		Select("id").
+		Where("status = ?", "new").
+		OrderBy("id").
+		Limit(5).
+		Union(false, sqlf.From("tasks").Select("id").Where("status = ?", "wip"))
+	defer q2.Close()
+	require.Equal(t, "SELECT id FROM tasks WHERE status = $1 UNION SELECT id FROM tasks WHERE status = $2 ORDER BY id LIMIT $3", q2.String())
+	require.Equal(t, []interface{}{"new", "wip", 5}, q2.Args())
+
+	// A filter added after a union applies to the first query
+	q.Where("owner_id = ?", 42)
+	require.Equal(t, "SELECT id, status FROM tasks WHERE status = $1 AND owner_id = $2 UNION ALL SELECT id, status FROM tasks WHERE status = $3 UNION ALL SELECT id, status FROM archived_tasks WHERE status = $4 ORDER BY id DESC LIMIT $5 OFFSET $6", q.String())
+	require.Equal(t, []interface{}{"new", 42, "wip", "done", 10, 20}, q.Args())
+}
+
+func TestIntersectExcept(t *testing.T) {
+	q := sqlf.PostgreSQL.From("tasks").
+		Select("id").
+		Where("status = ?", "new").
+		Intersect(false, sqlf.PostgreSQL.From("tasks").
+			Select("id").
+			Where("owner_id = ?", 42)).
+		Except(true, sqlf.PostgreSQL.From("archived_tasks").
+			Select("id").
+			Where("archived_at > ?", "2020-01-01"))
+	defer q.Close()
+	require.Equal(t, "SELECT id FROM tasks WHERE status = $1 INTERSECT SELECT id FROM tasks WHERE owner_id = $2 EXCEPT ALL SELECT id FROM archived_tasks WHERE archived_at > $3", q.String())
+	require.Equal(t, []interface{}{"new", 42, "2020-01-01"}, q.Args())
+}
+
+func TestSetJSONB(t *testing.T) {
+	q := sqlf.PostgreSQL.Update("users").
+		SetJSONB("profile", "address.city", "Paris").
+		SetJSONBRemove("contacts", "phone").
+		SetJSONBMerge("settings", map[string]string{"theme": "dark"}).
+		Where("id = ?", 42)
+	defer q.Close()
+	require.Equal(t, "UPDATE users SET profile=jsonb_set(profile, $1::text[], $2::jsonb), contacts=contacts #- $3::text[], settings=settings || $4::jsonb WHERE id = $5", q.String())
+
+	args := q.Args()
+	require.Len(t, args, 5)
+	require.Equal(t, `{"address","city"}`, args[0])
+	v, err := args[1].(driver.Valuer).Value()
+	require.NoError(t, err)
+	require.Equal(t, `"Paris"`, v)
+	v, err = args[3].(driver.Valuer).Value()
+	require.NoError(t, err)
+	require.Equal(t, `{"theme":"dark"}`, v)
+}
+
+func TestSetStructs(t *testing.T) {
+	type Base struct {
+		ID int64 `db:"id"`
+	}
+	type User struct {
+		Base
+		Name  string `db:"name"`
+		Email string `db:"email"`
+		age   int
+	}
+	q := sqlf.PostgreSQL.InsertInto("users").
+		SetStructs([]User{{Base{1}, "Ann", "ann@example.com", 30}, {Base{2}, "Bob", "bob@example.com", 40}})
+	defer q.Close()
+	require.Equal(t, "INSERT INTO users ( id, name, email ) VALUES ( $1, $2, $3 ), ( $4, $5, $6 )", q.String())
+	require.Equal(t, []interface{}{int64(1), "Ann", "ann@example.com", int64(2), "Bob", "bob@example.com"}, q.Args())
+
+	q2 := sqlf.InsertInto("users").SetStructs([]*User{{Name: "Ann"}})
+	defer q2.Close()
+	require.Equal(t, "INSERT INTO users ( id, name, email ) VALUES ( ?, ?, ? )", q2.String())
+
+	q3 := sqlf.InsertInto("users").SetStructs([]interface{}{User{}, &User{}})
+	defer q3.Close()
+	require.EqualError(t, q3.Err(), "sqlf: SetStructs expects elements of type sqlf_test.User, got *sqlf_test.User")
+
+	q4 := sqlf.InsertInto("users").SetStructs(User{})
+	defer q4.Close()
+	require.EqualError(t, q4.Err(), "sqlf: SetStructs expects a slice")
+}
+
+func TestSetBeforeVerb(t *testing.T) {
+	q := sqlf.From("users").Where("id = ?", 42)
+	defer q.Close()
+	require.NoError(t, q.Err())
+	q.Set("name", "John").Set("email", "john@example.com")
+	require.EqualError(t, q.Err(), "sqlf: Set is called for a statement with no INSERT or UPDATE clause")
+	require.Equal(t, q.Err(), q.Validate())
+}
+
+func TestErr(t *testing.T) {
+	db := new(recordingDB)
+	q := sqlf.From("users").Set("name", "John")
+	defer q.Close()
+
+	_, err := q.Exec(context.Background(), db)
+	require.Equal(t, q.Err(), err)
+	require.Equal(t, q.Err(), q.Query(context.Background(), db, nil))
+	require.Equal(t, q.Err(), q.QueryRow(context.Background(), db))
+	require.Empty(t, db.query)
+
+	// A clone keeps the error, an enclosing statement receives it
+	q2 := q.Clone()
+	require.Equal(t, q.Err(), q2.Err())
+	q3 := sqlf.From("orders").WhereInSubquery("user_id", q2)
+	defer q3.Close()
+	require.Equal(t, q.Err(), q3.Err())
+}
+
+func TestSetIfValid(t *testing.T) {
+	name := sql.NullString{String: "John", Valid: true}
+	email := sql.NullString{}
+	q := sqlf.PostgreSQL.Update("users").
+		SetIfValid("name", name).
+		SetIfValid("email", email).
+		SetIfValid("age", sql.NullInt64{Int64: 0, Valid: true}).
+		SetIfValid("phone", (*sql.NullString)(nil)).
+		SetIfValid("city", nil).
+		Where("id = ?", 42)
+	defer q.Close()
+	require.Equal(t, "UPDATE users SET name=$1, age=$2 WHERE id = $3", q.String())
+	require.Equal(t, []interface{}{name, sql.NullInt64{Int64: 0, Valid: true}, 42}, q.Args())
+}
+
+func TestComment(t *testing.T) {
+	q := sqlf.PostgreSQL.From("orders").
+		Select("id").
+		Where("status = ?", "new").
+		Comment("service:orders").
+		Comment("route:list?*/ DROP TABLE orders; /*")
+	defer q.Close()
+	require.Equal(t, "/* service:orders */ /* route:list? DROP TABLE orders;  */ SELECT id FROM orders WHERE status = $1", q.String())
+	require.Equal(t, []interface{}{"new"}, q.Args())
+
+	q2 := sqlf.PostgreSQL.From("orders").
+		Select("id").
+		Where("status = ?", "new").
+		SubQuery("user_id IN (", ")", sqlf.From("users").Comment("why?").Select("id").Where("name = ?", "Ann"))
+	defer q2.Close()
+	require.Equal(t, "SELECT id FROM orders WHERE status = $1 AND user_id IN (/* why? */ SELECT id FROM users WHERE name = $2)", q2.String())
+	require.Equal(t, []interface{}{"new", "Ann"}, q2.Args())
+
+	q3 := sqlf.MySQL.From("orders").Comment("why?").Select("id")
+	defer q3.Close()
+	require.Equal(t, "/* why? */ SELECT id FROM orders", q3.String())
+
+	q4 := sqlf.From("orders").Comment("why?").Select("id")
+	defer q4.Close()
+	require.Equal(t, "/* why? */ SELECT id FROM orders", q4.String())
+}
+
+func TestExplain(t *testing.T) {
+	for _, c := range []struct {
+		dialect *sqlf.Dialect
+		analyze bool
+		sql     string
+	}{
+		{sqlf.PostgreSQL, true, "/* report */ EXPLAIN ANALYZE SELECT id FROM orders WHERE user_id = $1"},
+		{sqlf.PostgreSQL, false, "/* report */ EXPLAIN SELECT id FROM orders WHERE user_id = $1"},
+		{sqlf.MySQL, false, "/* report */ EXPLAIN FORMAT=JSON SELECT id FROM orders WHERE user_id = ?"},
+		{sqlf.MySQL, true, "/* report */ EXPLAIN ANALYZE SELECT id FROM orders WHERE user_id = ?"},
+		{sqlf.SQLite, true, "/* report */ EXPLAIN QUERY PLAN SELECT id FROM orders WHERE user_id = ?"},
+	} {
+		q := c.dialect.From("orders").Select("id").Where("user_id = ?", 42).Comment("report")
+		plan := q.Clone().Explain(c.analyze)
+		require.Equal(t, c.sql, plan.String())
+		require.Equal(t, []interface{}{42}, plan.Args())
+		require.NotContains(t, q.String(), "EXPLAIN")
+		plan.Close()
+		q.Close()
+	}
+}
+
 func TestLimit(t *testing.T) {
 	q := sqlf.From("items").
 		Select("id").
@@ -305,3 +691,1055 @@ func TestBulkInsert(t *testing.T) {
 	require.Equal(t, "INSERT INTO vars ( no, val ) VALUES ( ?, ? ), ( ?, ? ), ( ?, ? ), ( ?, ? ), ( ?, ? )", q.String())
 	require.Len(t, q.Args(), 10)
 }
+
+func TestBuildOnce(t *testing.T) {
+	q := sqlf.PostgreSQL.From("table").Select("field").Where("id = ?", 42)
+	sql, args := q.BuildOnce()
+	require.Equal(t, "SELECT field FROM table WHERE id = $1", sql)
+	require.Equal(t, []interface{}{42}, args)
+
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			require.Equal(t, sql, q.String())
+			require.Equal(t, args, q.Args())
+		}()
+	}
+	wg.Wait()
+	q.Close()
+
+	// The snapshot outlives the statement
+	require.Equal(t, "SELECT field FROM table WHERE id = $1", sql)
+	require.Equal(t, []interface{}{42}, args)
+}
+
+func TestArgCount(t *testing.T) {
+	q := sqlf.PostgreSQL.From("table").Select("field").Where("id IN (?, ?)", 1, 2)
+	defer q.Close()
+	require.Equal(t, 2, q.ArgCount())
+	q.Limit(10)
+	require.Equal(t, 3, q.ArgCount())
+	require.Equal(t, "SELECT field FROM table WHERE id IN ($1, $2) LIMIT $3", q.String())
+	require.Equal(t, 3, q.ArgCount())
+}
+
+func TestBindQualified(t *testing.T) {
+	var order struct {
+		ID     int64   `db:"id"`
+		UserID int64   `db:"user_id"`
+		Amount float64 `db:"amount"`
+	}
+	q := sqlf.From("orders o").
+		BindQualified("o", &order).
+		Join("users u", "u.id = o.user_id").
+		Where("u.email = ?", "user@example.com")
+	defer q.Close()
+	require.Equal(t, "SELECT o.id, o.user_id, o.amount FROM orders o JOIN users u ON (u.id = o.user_id) WHERE u.email = ?", q.String())
+	require.Equal(t, []interface{}{&order.ID, &order.UserID, &order.Amount}, q.Dest())
+}
+
+func TestArgsCopy(t *testing.T) {
+	var field int
+	q := sqlf.From("table").Select("field").To(&field).Where("id = ?", 42)
+	args, dest := q.ArgsCopy(), q.DestCopy()
+	q.Close()
+
+	require.Equal(t, []interface{}{42}, args)
+	require.Equal(t, []interface{}{&field}, dest)
+}
+
+func TestHasClause(t *testing.T) {
+	q := sqlf.From("table").Select("id").Where("id > ?", 42)
+	defer q.Close()
+
+	require.True(t, q.HasClause("SELECT"))
+	require.True(t, q.HasClause("where"))
+	require.False(t, q.HasClause("ORDER BY"))
+	require.False(t, q.HasClause("LIMIT"))
+	require.False(t, q.HasClause("FOR UPDATE"))
+
+	q.OrderBy("id").Limit(10)
+	require.True(t, q.HasClause("ORDER BY"))
+	require.True(t, q.HasClause("Limit"))
+	require.False(t, q.HasClause("OFFSET"))
+}
+
+func TestClauseText(t *testing.T) {
+	q := sqlf.PostgreSQL.From("users").
+		Select("id").
+		Where("status = ?", "new").
+		Where("age > ?", 18).
+		OrderBy("id").
+		Limit(10)
+	defer q.Close()
+
+	text, ok := q.ClauseText("where")
+	require.True(t, ok)
+	require.Equal(t, "WHERE status = $1 AND age > $2", text)
+
+	text, ok = q.ClauseText("LIMIT")
+	require.True(t, ok)
+	require.Equal(t, "LIMIT $3", text)
+
+	text, ok = q.ClauseText("SELECT")
+	require.True(t, ok)
+	require.Equal(t, "SELECT id", text)
+
+	_, ok = q.ClauseText("GROUP BY")
+	require.False(t, ok)
+	_, ok = q.ClauseText("FOR UPDATE")
+	require.False(t, ok)
+}
+
+func TestMapArgs(t *testing.T) {
+	q := sqlf.PostgreSQL.From("table").
+		Select("field").
+		Where("id = ANY(?)", []int64{1, 2}).
+		Where("status = ?", "new").
+		MapArgs(func(i int, v interface{}) interface{} {
+			if ids, ok := v.([]int64); ok {
+				return fmt.Sprint(ids)
+			}
+			return v
+		})
+	defer q.Close()
+
+	require.Equal(t, "SELECT field FROM table WHERE id = ANY($1) AND status = $2", q.String())
+	require.Equal(t, []interface{}{"[1 2]", "new"}, q.Args())
+}
+
+func TestAppendTo(t *testing.T) {
+	q := sqlf.From("table").
+		Select("id").
+		Where("id > ?", 42).
+		OrderBy("id").
+		AppendTo("where", "id < ?", 1000).
+		AppendTo("SELECT", "name").
+		AppendTo("FOR UPDATE", "")
+	defer q.Close()
+	require.Equal(t, "SELECT id, name FROM table WHERE id > ? AND id < ? ORDER BY id FOR UPDATE", q.String())
+	require.Equal(t, []interface{}{42, 1000}, q.Args())
+}
+
+func TestReturningStructSQL(t *testing.T) {
+	var u struct {
+		ID   int64  `db:"id"`
+		Name string `db:"name"`
+	}
+	q := sqlf.InsertInto("users").
+		Set("name", "User").
+		ReturningStruct(&u)
+	defer q.Close()
+	require.Equal(t, "INSERT INTO users ( name ) VALUES ( ? ) RETURNING id, name", q.String())
+	require.EqualValues(t, []interface{}{&u.ID, &u.Name}, q.Dest())
+}
+
+func TestJoinIf(t *testing.T) {
+	q := sqlf.From("orders o").Select("id").
+		JoinIf(true, "users u", "u.id = o.user_id").
+		LeftJoinIf(false, "items i", "i.order_id = o.id").
+		RightJoinIf(false, "sellers s", "s.id = o.seller_id").
+		FullJoinIf(true, "notes n", "n.order_id = o.id")
+	defer q.Close()
+	require.Equal(t, "SELECT id FROM orders o JOIN users u ON (u.id = o.user_id) FULL JOIN notes n ON (n.order_id = o.id)", q.String())
+
+	allocs := testing.AllocsPerRun(100, func() {
+		q.JoinIf(false, "users u", "u.id = o.user_id")
+	})
+	require.Zero(t, allocs)
+}
+
+func TestPeek(t *testing.T) {
+	q := sqlf.From("table").Select("id").Where("id > ?", 42)
+	defer q.Close()
+
+	sql, args := q.Peek()
+	require.Equal(t, "SELECT id FROM table WHERE id > ?", sql)
+	require.Equal(t, []interface{}{42}, args)
+
+	q.Where("id < ?", 1000)
+	sql, args = q.Peek()
+	require.Equal(t, "SELECT id FROM table WHERE id > ? AND id < ?", sql)
+	require.Equal(t, []interface{}{42, 1000}, args)
+	require.Equal(t, sql, q.String())
+}
+
+func TestWhereLike(t *testing.T) {
+	q := sqlf.From("users").Select("id").
+		WhereLike("name", "John%").
+		WhereILike("email", "%@example.com")
+	defer q.Close()
+	require.Equal(t, "SELECT id FROM users WHERE name LIKE ? AND LOWER(email) LIKE LOWER(?)", q.String())
+	require.Equal(t, []interface{}{"John%", "%@example.com"}, q.Args())
+
+	q2 := sqlf.PostgreSQL.From("users").Select("id").
+		WhereLike("name", "John%").
+		WhereILike("email", "%@example.com")
+	defer q2.Close()
+	require.Equal(t, "SELECT id FROM users WHERE name LIKE $1 AND email ILIKE $2", q2.String())
+}
+
+func TestEscapeLike(t *testing.T) {
+	require.Equal(t, "plain", sqlf.EscapeLike("plain"))
+	require.Equal(t, `100\%\_off\\`, sqlf.EscapeLike(`100%_off\`))
+}
+
+func TestWhereAny(t *testing.T) {
+	ids := []int64{1, 2, 3}
+	q := sqlf.From("users").Select("name").WhereAny("id", ids)
+	defer q.Close()
+	require.Equal(t, "SELECT name FROM users WHERE id IN (?,?,?)", q.String())
+	require.Equal(t, []interface{}{int64(1), int64(2), int64(3)}, q.Args())
+
+	q2 := sqlf.PostgreSQL.From("users").Select("name").WhereAny("id", ids)
+	defer q2.Close()
+	require.Equal(t, "SELECT name FROM users WHERE id = ANY($1)", q2.String())
+	require.Equal(t, []interface{}{ids}, q2.Args())
+}
+
+func TestValues(t *testing.T) {
+	q := sqlf.PostgreSQL.From("").
+		SubQuery("(", ") AS t(id, name)",
+			sqlf.Values([]string{"id", "name"}, [][]interface{}{
+				{1, "a"},
+				{2, nil},
+			})).
+		Select("t.id, t.name").
+		Where("t.id > ?", 0)
+	defer q.Close()
+	require.NoError(t, q.Err())
+	require.Equal(t, "SELECT t.id, t.name FROM (VALUES ($1, $2), ($3, $4)) AS t(id, name) WHERE t.id > $5", q.String())
+	require.Equal(t, []interface{}{1, "a", 2, nil, 0}, q.Args())
+
+	for _, rows := range [][][]interface{}{
+		{{1, 2, 3}, {4, 5}},
+		{{1, 2}, {4}},
+		{},
+	} {
+		v := sqlf.Values([]string{"a", "b"}, rows)
+		require.Error(t, v.Err())
+		v.Close()
+	}
+	v := sqlf.Values(nil, [][]interface{}{{1}})
+	defer v.Close()
+	require.Error(t, v.Err())
+}
+
+func TestGroupBy(t *testing.T) {
+	q := sqlf.From("orders").
+		Select("region, product, SUM(amount)").
+		GroupBy("region", "product").
+		GroupBy("year")
+	defer q.Close()
+	require.Equal(t, "SELECT region, product, SUM(amount) FROM orders GROUP BY region, product, year", q.String())
+}
+
+func TestOrHaving(t *testing.T) {
+	q := sqlf.From("orders").
+		Select("user_id").
+		GroupBy("user_id").
+		Having("SUM(amount) > ?", 1000).
+		OrHaving("COUNT(*) > ?", 10).
+		Having("MAX(amount) < ?", 500).
+		Limit(5)
+	defer q.Close()
+	require.Equal(t, "SELECT user_id FROM orders GROUP BY user_id HAVING SUM(amount) > ? OR COUNT(*) > ? AND MAX(amount) < ? LIMIT ?", q.String())
+	require.Equal(t, []interface{}{1000, 10, 500, 5}, q.Args())
+}
+
+func TestWriteTo(t *testing.T) {
+	sqlf.PostgreSQL.ClearCache()
+	var buf strings.Builder
+	for i := 0; i < 2; i++ {
+		q := sqlf.PostgreSQL.From("table").Select("id").Where("id > ?", 42).Where("id < ?", 1000)
+		n, err := q.WriteTo(&buf)
+		q.Close()
+		require.NoError(t, err)
+		require.EqualValues(t, 46, n)
+		buf.WriteString(";")
+	}
+	require.Equal(t, "SELECT id FROM table WHERE id > $1 AND id < $2;SELECT id FROM table WHERE id > $1 AND id < $2;", buf.String())
+}
+
+func TestIn(t *testing.T) {
+	q := sqlf.From("orders").Select("id").Where("status").In("new", "wip")
+	defer q.Close()
+	require.Equal(t, "SELECT id FROM orders WHERE status IN (?,?)", q.String())
+	require.Equal(t, []interface{}{"new", "wip"}, q.Args())
+
+	args := make([]interface{}, 300)
+	q2 := sqlf.From("orders").Select("id").Where("id").In(args...)
+	defer q2.Close()
+	require.Equal(t, "SELECT id FROM orders WHERE id IN ("+strings.Repeat("?,", 299)+"?)", q2.String())
+}
+
+func TestInNullable(t *testing.T) {
+	q := sqlf.PostgreSQL.From("nodes").Select("id").Where("parent_id").InNullable(1, nil, 2).Where("kind = ?", "leaf")
+	defer q.Close()
+	require.Equal(t, "SELECT id FROM nodes WHERE parent_id IN ($1,NULL,$2) AND kind = $3", q.String())
+	require.Equal(t, []interface{}{1, 2, "leaf"}, q.Args())
+
+	q2 := sqlf.From("nodes").Select("id").Where("parent_id").InNullable(nil)
+	defer q2.Close()
+	require.Equal(t, "SELECT id FROM nodes WHERE parent_id IN (NULL)", q2.String())
+	require.Empty(t, q2.Args())
+}
+
+func TestWhereInSubquery(t *testing.T) {
+	q := sqlf.PostgreSQL.From("users").
+		Select("id").
+		WhereInSubquery("id", sqlf.PostgreSQL.From("bans").Select("user_id").Where("until > ?", 100)).
+		Where("is_active = ?", true).
+		WhereNotInSubquery("id", sqlf.From("admins").Select("user_id").Where("level > ?", 1))
+	defer q.Close()
+	require.Equal(t, "SELECT id FROM users WHERE id IN (SELECT user_id FROM bans WHERE until > $1) AND is_active = $2 AND id NOT IN (SELECT user_id FROM admins WHERE level > $3)", q.String())
+	require.Equal(t, []interface{}{100, true, 1}, q.Args())
+}
+
+func TestWhereNot(t *testing.T) {
+	q := sqlf.PostgreSQL.From("orders").
+		Select("id").
+		Where("user_id = ?", 1).
+		WhereNot("status = ? OR amount > ?", "new", 100)
+	defer q.Close()
+	require.Equal(t, "SELECT id FROM orders WHERE user_id = $1 AND NOT (status = $2 OR amount > $3)", q.String())
+	require.Equal(t, []interface{}{1, "new", 100}, q.Args())
+}
+
+func TestAndWhereFrom(t *testing.T) {
+	filter := sqlf.PostgreSQL.From("").
+		Select("ignored = ?", 0).
+		Where("is_active").
+		Where("region = ? OR region = ?", "eu", "us").
+		WhereRaw("tags ? 'vip'")
+	defer filter.Close()
+
+	q := sqlf.PostgreSQL.From("users").
+		Select("id").
+		Where("age > ?", 18).
+		AndWhereFrom(filter).
+		Limit(10)
+	defer q.Close()
+	require.Equal(t, "SELECT id FROM users WHERE age > $1 AND (is_active AND region = $2 OR region = $3 AND tags ? 'vip') LIMIT $4", q.String())
+	require.Equal(t, []interface{}{18, "eu", "us", 10}, q.Args())
+
+	empty := sqlf.From("users")
+	defer empty.Close()
+	q2 := sqlf.From("users").Select("id").AndWhereFrom(empty)
+	defer q2.Close()
+	require.Equal(t, "SELECT id FROM users", q2.String())
+
+	// ?N placeholders can't be shared with dialects passing arguments as many times as they're used
+	pg := sqlf.PostgreSQL.From("").Where("x <= ?1 AND y >= ?1", 5)
+	defer pg.Close()
+	q3 := sqlf.MySQL.From("points").Select("id").AndWhereFrom(pg)
+	defer q3.Close()
+	require.Error(t, q3.Err())
+
+	q4 := sqlf.SQLite.From("points").Select("id").Where("id > ?", 0).AndWhereFrom(pg)
+	defer q4.Close()
+	require.NoError(t, q4.Err())
+	require.Equal(t, "SELECT id FROM points WHERE id > ? AND (x <= ? AND y >= ?2)", q4.String())
+	require.Equal(t, []interface{}{0, 5}, q4.Args())
+}
+
+func TestWhereEq(t *testing.T) {
+	q := sqlf.PostgreSQL.From("users").
+		Select("id").
+		Where("age > ?", 18).
+		WhereEq(map[string]interface{}{
+			"status":     "active",
+			"deleted_at": nil,
+			"region":     "eu",
+		})
+	defer q.Close()
+	require.Equal(t, "SELECT id FROM users WHERE age > $1 AND deleted_at IS NULL AND region = $2 AND status = $3", q.String())
+	require.Equal(t, []interface{}{18, "eu", "active"}, q.Args())
+
+	q2 := sqlf.From("users").Select("id").WhereEq(nil)
+	defer q2.Close()
+	require.Equal(t, "SELECT id FROM users", q2.String())
+}
+
+func TestWhereAll(t *testing.T) {
+	q := sqlf.PostgreSQL.From("orders").
+		Select("id").
+		Where("user_id = ?", 1).
+		WhereAll(
+			sqlf.Cond{Expr: "status = ?", Args: []interface{}{"new"}},
+			sqlf.Cond{Expr: "amount BETWEEN ? AND ?", Args: []interface{}{100, 200}},
+		).
+		WhereAll()
+	defer q.Close()
+	require.Equal(t, "SELECT id FROM orders WHERE user_id = $1 AND (status = $2 AND amount BETWEEN $3 AND $4)", q.String())
+	require.Equal(t, []interface{}{1, "new", 100, 200}, q.Args())
+}
+
+func TestInsertSelect(t *testing.T) {
+	q := sqlf.PostgreSQL.InsertInto("archive").
+		InsertSelect([]string{"a", "b"},
+			sqlf.PostgreSQL.From("live").
+				Select("a, b").
+				Where("ts < ?", 100)).
+		Returning("id")
+	defer q.Close()
+	require.Equal(t, "INSERT INTO archive ( a, b ) SELECT a, b FROM live WHERE ts < $1 RETURNING id", q.String())
+	require.Equal(t, []interface{}{100}, q.Args())
+
+	q2 := sqlf.InsertInto("archive").
+		Set("a", 1).
+		InsertSelect(nil, sqlf.From("live").Select("*"))
+	defer q2.Close()
+	require.Equal(t, "INSERT INTO archive SELECT * FROM live", q2.String())
+	require.Empty(t, q2.Args())
+}
+
+func TestCrossApply(t *testing.T) {
+	q := sqlf.From("users u").
+		Select("u.name, o.total").
+		CrossApply(sqlf.PostgreSQL.From("orders").
+			Select("TOP 1 total").
+			Where("user_id = u.id").
+			Where("total > ?", 10).
+			OrderBy("total DESC"), "o").
+		OuterApply(sqlf.From("notes").
+			Select("text").
+			Where("user_id = u.id AND kind = ?", "memo"), "n").
+		Where("u.id = ?", 1)
+	defer q.Close()
+	require.Equal(t, "SELECT u.name, o.total FROM users u CROSS APPLY (SELECT TOP 1 total FROM orders WHERE user_id = u.id AND total > ? ORDER BY total DESC) o OUTER APPLY (SELECT text FROM notes WHERE user_id = u.id AND kind = ?) n WHERE u.id = ?", q.String())
+	require.Equal(t, []interface{}{10, "memo", 1}, q.Args())
+}
+
+func TestPgManyPlaceholders(t *testing.T) {
+	q := sqlf.PostgreSQL.From("table").Select("id")
+	defer q.Close()
+	for i := 1; i <= 12; i++ {
+		q.Where(fmt.Sprintf("f%d = ?", i), i)
+	}
+	sql := q.String()
+	require.Contains(t, sql, "f9 = $9 AND f10 = $10 AND f11 = $11 AND f12 = $12")
+	require.Len(t, q.Args(), 12)
+}
+
+func TestReturningInserted(t *testing.T) {
+	var (
+		id       int64
+		inserted bool
+	)
+	q := sqlf.PostgreSQL.InsertInto("counters").
+		Set("name", "hits").
+		Clause("ON CONFLICT (name) DO UPDATE SET value = counters.value + 1").
+		Returning("id").To(&id).
+		ReturningInserted(&inserted)
+	defer q.Close()
+	require.Equal(t, "INSERT INTO counters ( name ) VALUES ( $1 ) ON CONFLICT (name) DO UPDATE SET value = counters.value + 1 RETURNING id, (xmax = 0) AS inserted", q.String())
+	require.Equal(t, []interface{}{&id, &inserted}, q.Dest())
+}
+
+func TestFetchFirst(t *testing.T) {
+	q := sqlf.PostgreSQL.From("scores").
+		Select("name").
+		Where("score > ?", 0).
+		OrderBy("score DESC").
+		Limit(5).
+		Offset(20).
+		FetchFirst(10, true)
+	defer q.Close()
+	require.Equal(t, "SELECT name FROM scores WHERE score > $1 ORDER BY score DESC FETCH FIRST $2 ROWS WITH TIES OFFSET $3", q.String())
+	require.Equal(t, []interface{}{0, 10, 20}, q.Args())
+
+	q.FetchFirst(3, false).Limit(4)
+	require.Equal(t, "SELECT name FROM scores WHERE score > $1 ORDER BY score DESC FETCH FIRST $2 ROWS ONLY OFFSET $3", q.String())
+	require.Equal(t, []interface{}{0, 4, 20}, q.Args())
+}
+
+func TestLimitExpr(t *testing.T) {
+	q := sqlf.From("items").
+		Select("id").
+		Where("id > ?", 42).
+		Limit(10).
+		LimitExpr("(SELECT max_rows FROM settings)").
+		Offset(5)
+	defer q.Close()
+	require.Equal(t, "SELECT id FROM items WHERE id > ? LIMIT (SELECT max_rows FROM settings) OFFSET ?", q.String())
+	require.Equal(t, []interface{}{42, 5}, q.Args())
+
+	q.Limit(20)
+	require.Equal(t, "SELECT id FROM items WHERE id > ? LIMIT ? OFFSET ?", q.String())
+	require.Equal(t, []interface{}{42, 20, 5}, q.Args())
+}
+
+func TestLimitAll(t *testing.T) {
+	q := sqlf.PostgreSQL.From("items").Select("id").Limit(10).Offset(20).LimitAll()
+	defer q.Close()
+	require.Equal(t, "SELECT id FROM items LIMIT ALL OFFSET $1", q.String())
+	require.Equal(t, []interface{}{20}, q.Args())
+
+	q2 := sqlf.From("items").Select("id").Limit(10).Offset(20).LimitAll()
+	defer q2.Close()
+	require.Equal(t, "SELECT id FROM items OFFSET ?", q2.String())
+	require.Equal(t, []interface{}{20}, q2.Args())
+}
+
+func TestRemovedChunksCache(t *testing.T) {
+	q := sqlf.From("items").Select("id").Limit(10).Offset(20).LimitAll()
+	require.Equal(t, "SELECT id FROM items OFFSET ?", q.String())
+	q.Close()
+
+	// A statement with the removed clause kept must not reuse the cached SQL
+	q = sqlf.From("items").Select("id").Limit(10).Offset(20)
+	defer q.Close()
+	require.Equal(t, "SELECT id FROM items LIMIT ? OFFSET ?", q.String())
+	require.Equal(t, []interface{}{10, 20}, q.Args())
+}
+
+func TestExprSep(t *testing.T) {
+	q := sqlf.From("users").
+		Select("first_name").
+		ExprSep(" || ? || ", "last_name", " ").
+		Where("id = ?", 42)
+	defer q.Close()
+	require.Equal(t, "SELECT first_name || ? || last_name FROM users WHERE id = ?", q.String())
+	require.Equal(t, []interface{}{" ", 42}, q.Args())
+}
+
+func TestValidate(t *testing.T) {
+	q := sqlf.InsertInto("table").
+		Set("a", 1).
+		SetExpr("b", "COALESCE(?, ?)", nil, 0)
+	defer q.Close()
+	require.NoError(t, q.Validate())
+
+	q.SetExpr("c, d", "?", 1)
+	require.EqualError(t, q.Validate(), "sqlf: INSERT row 1 has 3 value(s) for 4 column(s)")
+
+	q2 := sqlf.InsertInto("table")
+	defer q2.Close()
+	q2.NewRow().Set("a", 1).Set("b", 2)
+	q2.NewRow().Set("a", 1)
+	require.EqualError(t, q2.Validate(), "sqlf: INSERT row 2 has 1 value(s) for 2 column(s)")
+
+	q3 := sqlf.From("table").Select("id")
+	defer q3.Close()
+	require.NoError(t, q3.Validate())
+}
+
+func TestTooManyArgs(t *testing.T) {
+	ids := make([]interface{}, sqlf.MaxPostgreSQLArgs+1)
+	q := sqlf.PostgreSQL.DeleteFrom("table").Where("id").In(ids...)
+	defer q.Close()
+
+	err := q.Validate()
+	var argsErr *sqlf.TooManyArgsError
+	require.True(t, errors.As(err, &argsErr))
+	require.Equal(t, sqlf.MaxPostgreSQLArgs+1, argsErr.Args)
+
+	_, err = q.Exec(context.Background(), nil)
+	require.Equal(t, argsErr, err)
+
+	q2 := sqlf.MySQL.DeleteFrom("table").Where("id").In(ids...)
+	defer q2.Close()
+	require.NoError(t, q2.Validate())
+}
+
+func TestWhereOp(t *testing.T) {
+	ids := []int{1, 2}
+	q := sqlf.PostgreSQL.From("items").
+		Select("id").
+		WhereOp("price", ">", sqlf.All, []float64{1.5, 2.5}).
+		WhereOp("id", "<>", sqlf.All, ids)
+	defer q.Close()
+	require.Equal(t, "SELECT id FROM items WHERE price > ALL($1) AND id <> ALL($2)", q.String())
+
+	q2 := sqlf.From("items").
+		Select("id").
+		WhereOp("id", "<>", sqlf.All, ids).
+		WhereOp("tag_id", "=", sqlf.Any, ids)
+	defer q2.Close()
+	require.Equal(t, "SELECT id FROM items WHERE id NOT IN (?,?) AND tag_id IN (?,?)", q2.String())
+	require.Equal(t, []interface{}{1, 2, 1, 2}, q2.Args())
+}
+
+func TestSelectAs(t *testing.T) {
+	var total float64
+	q := sqlf.From("orders").
+		Select("user_id").
+		SelectAs("SUM(amount) * ?", "total", 1.2).To(&total).
+		GroupBy("user_id")
+	defer q.Close()
+	require.Equal(t, "SELECT user_id, SUM(amount) * ? AS total FROM orders GROUP BY user_id", q.String())
+	require.Equal(t, []interface{}{1.2}, q.Args())
+	require.Equal(t, []interface{}{&total}, q.Dest())
+}
+
+func TestDistinct(t *testing.T) {
+	var u struct {
+		ID   int64  `db:"id"`
+		Name string `db:"name"`
+	}
+	q := sqlf.PostgreSQL.From("users").
+		Distinct().
+		Bind(&u).
+		Where("id > ?", 1)
+	defer q.Close()
+	require.Equal(t, "SELECT DISTINCT id, name FROM users WHERE id > $1", q.String())
+
+	q2 := sqlf.PostgreSQL.From("users").
+		Select("COALESCE(name, ?)", "n/a").
+		Bind(&u).
+		Distinct().
+		Distinct().
+		Where("id > ?", 1)
+	defer q2.Close()
+	require.Equal(t, "SELECT DISTINCT COALESCE(name, $1), id, name FROM users WHERE id > $2", q2.String())
+	require.Equal(t, []interface{}{"n/a", 1}, q2.Args())
+	require.Equal(t, 3, q2.SelectColumnCount())
+
+	// Statements differing by DISTINCT only are not mixed up by the cache
+	q3 := sqlf.PostgreSQL.From("users").Bind(&u).Where("id > ?", 1)
+	defer q3.Close()
+	require.Equal(t, "SELECT id, name FROM users WHERE id > $1", q3.String())
+}
+
+func TestInAppend(t *testing.T) {
+	q := sqlf.PostgreSQL.From("users").
+		Where("age > ?", 18).
+		Limit(5).
+		Where("id").In().
+		Select("name, ? AS kind", "user")
+	defer q.Close()
+	q.InAppend(1, 2).InAppend().InAppend(3)
+	require.NoError(t, q.Err())
+	require.Equal(t, "SELECT name, $1 AS kind FROM users WHERE age > $2 AND id IN ($3,$4,$5) LIMIT $6", q.String())
+	require.Equal(t, []interface{}{"user", 18, 1, 2, 3, 5}, q.Args())
+
+	q2 := sqlf.From("users").Where("id").In(1).Where("age > ?", 18).InAppend(2)
+	defer q2.Close()
+	require.EqualError(t, q2.Err(), "sqlf: InAppend is called with no preceding In call")
+	require.Equal(t, "FROM users WHERE id IN (?) AND age > ?", q2.String())
+}
+
+func TestSelectAt(t *testing.T) {
+	q := sqlf.PostgreSQL.From("users").
+		Select("name, COALESCE(email, ?)", "n/a").
+		Where("id > ?", 10).
+		Select("age + ?", 1).
+		SelectAt(0, "id").
+		SelectAt(2, "? AS kind", "user").
+		SelectAt(100, "score")
+	defer q.Close()
+	require.Equal(t, "SELECT id, name, $1 AS kind, COALESCE(email, $2), age + $3, score FROM users WHERE id > $4", q.String())
+	require.Equal(t, []interface{}{"user", "n/a", 1, 10}, q.Args())
+
+	q2 := sqlf.From("users").SelectAt(1, "id")
+	defer q2.Close()
+	require.Equal(t, "SELECT id FROM users", q2.String())
+}
+
+func TestToCount(t *testing.T) {
+	var id int64
+	q := sqlf.PostgreSQL.From("orders").
+		Select("id, amount").To(&id, nil).
+		Where("user_id = ?", 42).
+		OrderBy("id").
+		Limit(10).
+		Offset(20)
+	defer q.Close()
+
+	c := q.ToCount()
+	defer c.Close()
+	require.Equal(t, "SELECT COUNT(*) FROM orders WHERE user_id = $1", c.String())
+	require.Equal(t, []interface{}{42}, c.Args())
+	require.Empty(t, c.Dest())
+	require.Equal(t, "SELECT id, amount FROM orders WHERE user_id = $1 ORDER BY id LIMIT $2 OFFSET $3", q.String())
+	require.Equal(t, []interface{}{42, 10, 20}, q.Args())
+
+	q2 := sqlf.PostgreSQL.From("orders").
+		Select("user_id").
+		Where("amount > ?", 100).
+		GroupBy("user_id").
+		Having("COUNT(*) > ?", 1).
+		OrderBy("user_id").
+		Limit(10)
+	defer q2.Close()
+	c2 := q2.ToCount()
+	defer c2.Close()
+	require.Equal(t, "SELECT COUNT(*) FROM (SELECT user_id FROM orders WHERE amount > $1 GROUP BY user_id HAVING COUNT(*) > $2) x", c2.String())
+	require.Equal(t, []interface{}{100, 1}, c2.Args())
+
+	q3 := sqlf.From("orders").Select("DISTINCT user_id")
+	defer q3.Close()
+	c3 := q3.ToCount()
+	defer c3.Close()
+	require.Equal(t, "SELECT COUNT(*) FROM (SELECT DISTINCT user_id FROM orders) x", c3.String())
+
+	q4 := sqlf.From("orders").Select("id").Union(true, sqlf.From("archive").Select("id"))
+	defer q4.Close()
+	c4 := q4.ToCount()
+	defer c4.Close()
+	require.Equal(t, "SELECT COUNT(*) FROM (SELECT id FROM orders UNION ALL SELECT id FROM archive) x", c4.String())
+}
+
+func TestSelectColumnCount(t *testing.T) {
+	q := sqlf.From("orders").
+		Select("id, COALESCE(amount, 0)").
+		Where("id > ?", 42).
+		Select("(SELECT MAX(ts) FROM events WHERE order_id = orders.id AND kind IN ('a', 'b'))").
+		Select("'x, y' AS label")
+	defer q.Close()
+	require.Equal(t, 4, q.SelectColumnCount())
+
+	q2 := sqlf.DeleteFrom("orders")
+	defer q2.Close()
+	require.Equal(t, 0, q2.SelectColumnCount())
+}
+
+func TestWhereRaw(t *testing.T) {
+	q := sqlf.PostgreSQL.From("docs").
+		Select("id").
+		WhereRaw("tags ? 'urgent'").
+		Where("owner_id = ?", 42).
+		WhereRaw("tags ?| array['a', 'b']")
+	defer q.Close()
+	require.Equal(t, "SELECT id FROM docs WHERE tags ? 'urgent' AND owner_id = $1 AND tags ?| array['a', 'b']", q.String())
+	require.Equal(t, []interface{}{42}, q.Args())
+
+	q2 := sqlf.From("docs").Select("id").WhereRaw("title = '?'")
+	defer q2.Close()
+	require.Equal(t, "SELECT id FROM docs WHERE title = '?'", q2.String())
+
+	q3 := sqlf.PostgreSQL.From("users").
+		Select("id").
+		Where("name = ?", "Ann").
+		SubQuery("id IN (", ")", sqlf.From("docs").Select("owner_id").WhereRaw("tags ? 'x'").Where("kind = ?", 1))
+	defer q3.Close()
+	require.Equal(t, "SELECT id FROM users WHERE name = $1 AND id IN (SELECT owner_id FROM docs WHERE tags ? 'x' AND kind = $2)", q3.String())
+	require.Equal(t, []interface{}{"Ann", 1}, q3.Args())
+
+	q4 := sqlf.PostgreSQL.From("docs").
+		Select("id").
+		Union(true, sqlf.From("archive").Select("id").WhereRaw("tags ? 'x'"))
+	defer q4.Close()
+	require.Equal(t, "SELECT id FROM docs UNION ALL SELECT id FROM archive WHERE tags ? 'x'", q4.String())
+}
+
+func TestCloneReusesSQL(t *testing.T) {
+	q := sqlf.From("table").Select("field").Where("id = ?", 42)
+	defer q.Close()
+	sql := q.String()
+
+	// A clone gets the built SQL of the original statement
+	// and doesn't render it again until modified.
+	allocs := testing.AllocsPerRun(100, func() {
+		q2 := q.Clone()
+		if q2.String() != sql {
+			t.Fail()
+		}
+		q2.Close()
+	})
+	if !raceEnabled {
+		require.Zero(t, allocs)
+	}
+
+	q2 := q.Clone()
+	defer q2.Close()
+	q2.Where("id < ?", 100)
+	require.Equal(t, "SELECT field FROM table WHERE id = ? AND id < ?", q2.String())
+	require.Equal(t, sql, q.String())
+}
+
+func TestGrow(t *testing.T) {
+	q := sqlf.From("table").Grow(100)
+	defer q.Close()
+	for n := 1; n <= 50; n++ {
+		q.Select(fmt.Sprintf("field_%d", n)).Where(fmt.Sprintf("field_%d > ?", n), n)
+	}
+	require.Equal(t, 50, q.SelectColumnCount())
+	require.Len(t, q.Args(), 50)
+	require.Contains(t, q.String(), "SELECT field_1, field_2, ")
+}
+
+func TestWhereAnyColumnLike(t *testing.T) {
+	q := sqlf.PostgreSQL.From("users").
+		Select("id").
+		Where("is_active").
+		WhereAnyColumnLike([]string{"name", "email"}, "%john%").
+		WhereAnyColumnLike(nil, "%john%")
+	defer q.Close()
+	require.Equal(t, "SELECT id FROM users WHERE is_active AND (name ILIKE $1 OR email ILIKE $2)", q.String())
+	require.Equal(t, []interface{}{"%john%", "%john%"}, q.Args())
+
+	q2 := sqlf.From("users").Select("id").WhereAnyColumnLike([]string{"name", "email"}, "%john%")
+	defer q2.Close()
+	require.Equal(t, "SELECT id FROM users WHERE (LOWER(name) LIKE LOWER(?) OR LOWER(email) LIKE LOWER(?))", q2.String())
+
+	q3 := sqlf.From("users").Select("id").WhereAnyColumnLike([]string{"name"}, "%john%")
+	defer q3.Close()
+	require.Equal(t, "SELECT id FROM users WHERE LOWER(name) LIKE LOWER(?)", q3.String())
+}
+
+func TestSelectColumns(t *testing.T) {
+	allowed := map[string]bool{"id": true, "name": true, "email": true}
+	q := sqlf.From("users").Select("id")
+	defer q.Close()
+
+	require.NoError(t, q.SelectColumns(allowed, []string{"name", "email"}))
+	require.NoError(t, q.SelectColumns(allowed, nil))
+	require.EqualError(t, q.SelectColumns(allowed, []string{"name", "password"}), `sqlf: column "password" is not allowed`)
+	require.Equal(t, "SELECT id, name, email FROM users", q.String())
+}
+
+func TestOrderBySpec(t *testing.T) {
+	allowed := map[string]string{"name": "u.name", "created": "u.created_at"}
+	q := sqlf.From("users u").Select("id")
+	defer q.Close()
+
+	require.NoError(t, q.OrderBySpec("name, -created,", allowed))
+	require.NoError(t, q.OrderBySpec("", allowed))
+	require.EqualError(t, q.OrderBySpec("name,-password", allowed), `sqlf: sort field "password" is not allowed`)
+	require.EqualError(t, q.OrderBySpec("name; DROP TABLE users", allowed), `sqlf: sort field "name; DROP TABLE users" is not allowed`)
+	require.Equal(t, "SELECT id FROM users u ORDER BY u.name, u.created_at DESC", q.String())
+}
+
+func TestWhereKeyset(t *testing.T) {
+	q := sqlf.PostgreSQL.From("order_lines").
+		Select("qty").
+		Where("qty > ?", 0).
+		WhereKeyset([]string{"order_id", "line_no"}, [][]interface{}{{1, 10}, {2, 20}})
+	defer q.Close()
+	require.Equal(t, "SELECT qty FROM order_lines WHERE qty > $1 AND ((order_id = $2 AND line_no = $3) OR (order_id = $4 AND line_no = $5))", q.String())
+	require.Equal(t, []interface{}{0, 1, 10, 2, 20}, q.Args())
+
+	q2 := sqlf.From("users").Select("id").WhereKeyset([]string{"id"}, [][]interface{}{{1}})
+	defer q2.Close()
+	require.Equal(t, "SELECT id FROM users WHERE id = ?", q2.String())
+
+	q3 := sqlf.From("users").Select("id").WhereKeyset([]string{"id"}, nil)
+	defer q3.Close()
+	require.Equal(t, "SELECT id FROM users WHERE 1 = 0", q3.String())
+	require.Empty(t, q3.Args())
+
+	q4 := sqlf.From("users").Select("id").WhereKeyset([]string{"id", "name"}, [][]interface{}{{1}})
+	defer q4.Close()
+	require.Error(t, q4.Err())
+
+	q5 := sqlf.From("users").Select("id").WhereKeyset(nil, [][]interface{}{{1}})
+	defer q5.Close()
+	require.Error(t, q5.Err())
+}
+
+func TestCollate(t *testing.T) {
+	q := sqlf.SQLite.From("users").
+		Select("id").
+		Where(sqlf.Collate("name = ?", "NOCASE"), "john").
+		OrderByCollate("name", "NOCASE")
+	defer q.Close()
+	require.Equal(t, "SELECT id FROM users WHERE name = ? COLLATE NOCASE ORDER BY name COLLATE NOCASE", q.String())
+	require.Equal(t, []interface{}{"john"}, q.Args())
+
+	q2 := sqlf.PostgreSQL.From("users").
+		Select("id").
+		WhereLike(sqlf.Collate("name", `"C"`), "j%").
+		OrderByCollate("name", `"C"`).
+		OrderBy("id")
+	defer q2.Close()
+	require.Equal(t, `SELECT id FROM users WHERE name COLLATE "C" LIKE $1 ORDER BY name COLLATE "C", id`, q2.String())
+}
+
+func TestMySQLMultiTableDelete(t *testing.T) {
+	q := sqlf.MySQL.DeleteFrom("orders o").
+		Join("users u", "u.id = o.user_id").
+		LeftJoin("payments p", "p.order_id = o.id").
+		Where("u.is_deleted = ?", true)
+	defer q.Close()
+	require.Equal(t, "DELETE o FROM orders o JOIN users u ON (u.id = o.user_id) LEFT JOIN payments p ON (p.order_id = o.id) WHERE u.is_deleted = ?", q.String())
+	require.Equal(t, []interface{}{true}, q.Args())
+
+	q2 := sqlf.MySQL.DeleteFrom("orders").Join("users", "users.id = orders.user_id")
+	defer q2.Close()
+	require.Equal(t, "DELETE orders FROM orders JOIN users ON (users.id = orders.user_id)", q2.String())
+
+	q3 := sqlf.DeleteFrom("orders").Join("users", "users.id = orders.user_id")
+	defer q3.Close()
+	require.Equal(t, "DELETE FROM orders JOIN users ON (users.id = orders.user_id)", q3.String())
+}
+
+func TestWhereTupleIn(t *testing.T) {
+	q := sqlf.PostgreSQL.From("order_lines").
+		Select("qty").
+		Where("qty > ?", 0).
+		WhereTupleIn([]string{"order_id", "line_no"}, [][]interface{}{{1, 10}, {2, 20}})
+	defer q.Close()
+	require.Equal(t, "SELECT qty FROM order_lines WHERE qty > $1 AND (order_id, line_no) IN (($2, $3), ($4, $5))", q.String())
+	require.Equal(t, []interface{}{0, 1, 10, 2, 20}, q.Args())
+
+	q2 := sqlf.From("order_lines").Select("qty").WhereTupleIn([]string{"order_id", "line_no"}, nil)
+	defer q2.Close()
+	require.Equal(t, "SELECT qty FROM order_lines WHERE 1 = 0", q2.String())
+	require.Empty(t, q2.Args())
+
+	q3 := sqlf.From("order_lines").Select("qty").WhereTupleIn([]string{"order_id", "line_no"}, [][]interface{}{{1, 10}, {2}})
+	defer q3.Close()
+	require.Error(t, q3.Err())
+
+	q4 := sqlf.From("order_lines").Select("qty").WhereTupleIn(nil, [][]interface{}{{1}})
+	defer q4.Close()
+	require.Error(t, q4.Err())
+}
+
+func TestGroupingExtensions(t *testing.T) {
+	q := sqlf.From("sales").
+		Select("region, product, SUM(amount)").
+		GroupByRollup("region", "product")
+	defer q.Close()
+	require.Equal(t, "SELECT region, product, SUM(amount) FROM sales GROUP BY ROLLUP (region, product)", q.String())
+
+	q2 := sqlf.From("sales").
+		Select("year, region, product, SUM(amount)").
+		GroupBy("year").
+		GroupByCube("region", "product")
+	defer q2.Close()
+	require.Equal(t, "SELECT year, region, product, SUM(amount) FROM sales GROUP BY year, CUBE (region, product)", q2.String())
+
+	q3 := sqlf.From("sales").
+		Select("region, product, SUM(amount)").
+		GroupByGroupingSets([]string{"region", "product"}, []string{"region"}, nil).
+		Having("SUM(amount) > ?", 100)
+	defer q3.Close()
+	require.Equal(t, "SELECT region, product, SUM(amount) FROM sales GROUP BY GROUPING SETS ((region, product), (region), ()) HAVING SUM(amount) > ?", q3.String())
+}
+
+func TestInsertIgnoreDialects(t *testing.T) {
+	for _, c := range []struct {
+		dialect *sqlf.Dialect
+		sql     string
+	}{
+		{sqlf.MySQL, "INSERT IGNORE INTO users ( id, name ) VALUES ( ?, ? )"},
+		{sqlf.SQLite, "INSERT OR IGNORE INTO users ( id, name ) VALUES ( ?, ? )"},
+		{sqlf.PostgreSQL, "INSERT INTO users ( id, name ) VALUES ( $1, $2 ) ON CONFLICT DO NOTHING RETURNING id"},
+	} {
+		q := c.dialect.InsertInto("users").
+			Set("id", 1).
+			InsertIgnore().
+			Set("name", "User 1")
+		if c.dialect == sqlf.PostgreSQL {
+			q.Returning("id")
+		}
+		require.Equal(t, c.sql, q.String())
+		require.Equal(t, []interface{}{1, "User 1"}, q.Args())
+		q.Close()
+	}
+}
+
+func TestUpsertDialects(t *testing.T) {
+	for _, c := range []struct {
+		dialect *sqlf.Dialect
+		update  []string
+		sql     string
+	}{
+		{sqlf.PostgreSQL, []string{"name", "email"}, "INSERT INTO users ( id, name, email ) VALUES ( $1, $2, $3 ) ON CONFLICT (id) DO UPDATE SET name = EXCLUDED.name, email = EXCLUDED.email RETURNING id"},
+		{sqlf.SQLite, []string{"name", "email"}, "INSERT INTO users ( id, name, email ) VALUES ( ?, ?, ? ) ON CONFLICT (id) DO UPDATE SET name = excluded.name, email = excluded.email"},
+		{sqlf.MySQL, []string{"name", "email"}, "INSERT INTO users ( id, name, email ) VALUES ( ?, ?, ? ) ON DUPLICATE KEY UPDATE name = VALUES(name), email = VALUES(email)"},
+		{sqlf.PostgreSQL, nil, "INSERT INTO users ( id, name, email ) VALUES ( $1, $2, $3 ) ON CONFLICT (id) DO NOTHING RETURNING id"},
+		{sqlf.MySQL, nil, "INSERT IGNORE INTO users ( id, name, email ) VALUES ( ?, ?, ? )"},
+	} {
+		q := c.dialect.InsertInto("users").
+			Set("id", 1).
+			Upsert([]string{"id"}, c.update).
+			Set("name", "User 1").
+			Set("email", "user1@example.com")
+		if c.dialect == sqlf.PostgreSQL {
+			q.Returning("id")
+		}
+		require.Equal(t, c.sql, q.String())
+		require.Equal(t, []interface{}{1, "User 1", "user1@example.com"}, q.Args())
+		q.Close()
+	}
+
+	// PostgreSQL can't update conflicting rows with no conflict target
+	q := sqlf.PostgreSQL.InsertInto("users").Set("id", 1).Upsert(nil, []string{"name"})
+	defer q.Close()
+	require.Error(t, q.Err())
+
+	q2 := sqlf.PostgreSQL.InsertInto("users").Set("id", 1).Upsert(nil, nil)
+	defer q2.Close()
+	require.NoError(t, q2.Err())
+	require.Equal(t, "INSERT INTO users ( id ) VALUES ( $1 ) ON CONFLICT DO NOTHING", q2.String())
+
+	q3 := sqlf.SQLite.InsertInto("users").Set("id", 1).Upsert(nil, []string{"name"})
+	defer q3.Close()
+	require.NoError(t, q3.Err())
+	require.Equal(t, "INSERT INTO users ( id ) VALUES ( ? ) ON CONFLICT DO UPDATE SET name = excluded.name", q3.String())
+}
+
+func TestUseIndex(t *testing.T) {
+	q := sqlf.MySQL.From("orders").
+		UseIndex("idx_created_at").
+		Select("id").
+		Where("created_at > ?", 1)
+	defer q.Close()
+	require.Equal(t, "SELECT id FROM orders USE INDEX (idx_created_at) WHERE created_at > ?", q.String())
+
+	q2 := sqlf.PostgreSQL.From("orders").UseIndex("idx_created_at").Select("id")
+	defer q2.Close()
+	require.Equal(t, "SELECT id FROM orders", q2.String())
+}
+
+func TestTableSample(t *testing.T) {
+	q := sqlf.PostgreSQL.Select("? AS kind", "sample").
+		From("events e").
+		TableSample("BERNOULLI", 2.5).
+		Join("users u", "u.id = e.user_id").
+		Where("e.duration > ?", 10)
+	defer q.Close()
+	require.NoError(t, q.Err())
+	require.Equal(t, "SELECT $1 AS kind FROM events e TABLESAMPLE BERNOULLI ($2) JOIN users u ON (u.id = e.user_id) WHERE e.duration > $3", q.String())
+	require.Equal(t, []interface{}{"sample", 2.5, 10}, q.Args())
+
+	q2 := sqlf.SQLite.From("events").TableSample("SYSTEM", 10).Select("id")
+	defer q2.Close()
+	require.EqualError(t, q2.Err(), "sqlf: TABLESAMPLE is not supported by MySQL and SQLite")
+	require.Equal(t, "SELECT id FROM events", q2.String())
+}
+
+func TestHint(t *testing.T) {
+	q := sqlf.PostgreSQL.Select("id, ? AS kind", "order").
+		From("orders o").
+		Where("id = ?", 1).
+		Hint("INDEX(o idx_created_at) */")
+	defer q.Close()
+	require.Equal(t, "SELECT /*+ INDEX(o idx_created_at)  */ id, $1 AS kind FROM orders o WHERE id = $2", q.String())
+	require.Equal(t, []interface{}{"order", 1}, q.Args())
+
+	q2 := sqlf.InsertInto("orders").
+		Hint("APPEND").
+		Set("id", 1)
+	defer q2.Close()
+	require.Equal(t, "INSERT /*+ APPEND */ INTO orders ( id ) VALUES ( ? )", q2.String())
+
+	q3 := sqlf.Update("orders").Set("status", "new").Hint("NO_INDEX(orders)")
+	defer q3.Close()
+	require.Equal(t, "UPDATE /*+ NO_INDEX(orders) */ orders SET status=?", q3.String())
+}
+
+func TestReplaceIntoSQL(t *testing.T) {
+	q := sqlf.MySQL.ReplaceInto("users").
+		Set("id", 1).
+		SetExpr("name", "CONCAT(?, ?)", "User ", 1)
+	defer q.Close()
+	require.Equal(t, "REPLACE INTO users ( id, name ) VALUES ( ?, CONCAT(?, ?) )", q.String())
+	require.Equal(t, []interface{}{1, "User ", 1}, q.Args())
+}
+
+func TestOrderByRandom(t *testing.T) {
+	q := sqlf.MySQL.From("quotes").Select("text").OrderBy("rating DESC").OrderByRandom().Limit(1)
+	defer q.Close()
+	require.Equal(t, "SELECT text FROM quotes ORDER BY rating DESC, RAND() LIMIT ?", q.String())
+
+	q2 := sqlf.PostgreSQL.From("quotes").Select("text").OrderByRandom()
+	defer q2.Close()
+	require.Equal(t, "SELECT text FROM quotes ORDER BY RANDOM()", q2.String())
+}
@@ -1,6 +1,12 @@
 package sqlf
 
 import (
+	"database/sql/driver"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
 	"unsafe"
 )
 
@@ -25,3 +31,272 @@ func insertAt(dest, src []interface{}, index int) []interface{} {
 func bufToString(buf *[]byte) string {
 	return *(*string)(unsafe.Pointer(buf))
 }
+
+// sliceArgs converts a slice or an array to a list of arguments.
+// Any other value is returned as a single argument.
+func sliceArgs(values interface{}) []interface{} {
+	if args, ok := values.([]interface{}); ok {
+		return args
+	}
+	v := reflect.ValueOf(values)
+	if v.Kind() != reflect.Slice && v.Kind() != reflect.Array {
+		return []interface{}{values}
+	}
+	args := make([]interface{}, v.Len())
+	for i := range args {
+		args[i] = v.Index(i).Interface()
+	}
+	return args
+}
+
+// splitTuples counts top-level comma-separated expressions in s.
+// Closing parentheses that have no matching opening ones
+// separate tuples, like in "?, ? ), ( ?, ?". Parentheses and commas
+// inside quoted strings and identifiers are ignored.
+//
+// splitTuples returns a number of expressions for every tuple.
+func splitTuples(s string) []int {
+	var (
+		counts   []int
+		depth    int
+		n        int
+		nonEmpty bool
+		quote    byte
+		between  bool
+	)
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		if quote != 0 {
+			if c == quote {
+				quote = 0
+			}
+			continue
+		}
+		switch c {
+		case '\'', '"', '`':
+			quote = c
+			nonEmpty = true
+		case '(':
+			if between {
+				// Opening parenthesis of a next tuple
+				between = false
+				continue
+			}
+			depth++
+			nonEmpty = true
+		case ')':
+			if depth == 0 {
+				if nonEmpty {
+					n++
+				}
+				counts = append(counts, n)
+				n, nonEmpty, between = 0, false, true
+				continue
+			}
+			depth--
+		case ',':
+			if depth == 0 && !between {
+				n++
+				nonEmpty = false
+				continue
+			}
+		case ' ', '\t', '\n', '\r':
+			continue
+		default:
+			nonEmpty = true
+		}
+	}
+	if !between || nonEmpty {
+		if nonEmpty {
+			n++
+		}
+		counts = append(counts, n)
+	}
+	return counts
+}
+
+// splitList splits s into top-level comma-separated expressions.
+// Commas inside parentheses, quoted strings and identifiers are ignored.
+func splitList(s string) []string {
+	var (
+		items []string
+		depth int
+		quote byte
+		start int
+	)
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		if quote != 0 {
+			if c == quote {
+				quote = 0
+			}
+			continue
+		}
+		switch c {
+		case '\'', '"', '`':
+			quote = c
+		case '(':
+			depth++
+		case ')':
+			depth--
+		case ',':
+			if depth == 0 {
+				items = append(items, strings.TrimSpace(s[start:i]))
+				start = i + 1
+			}
+		}
+	}
+	if item := strings.TrimSpace(s[start:]); item != "" || len(items) > 0 {
+		items = append(items, item)
+	}
+	return items
+}
+
+// countPlaceholders returns the number of ? placeholders in s
+// not escaped with a backslash.
+func countPlaceholders(s string) int {
+	n := 0
+	for i := 0; i < len(s); i++ {
+		switch s[i] {
+		case '\\':
+			i++
+		case '?':
+			if _, l := parseBackRef(s[i:]); l > 0 {
+				i += l - 1
+				continue
+			}
+			n++
+		}
+	}
+	return n
+}
+
+// hasIndexedPlaceholders reports if s contains ?N placeholders.
+func hasIndexedPlaceholders(s string) bool {
+	for i := 0; i < len(s)-1; i++ {
+		switch s[i] {
+		case '\\':
+			i++
+		case '?':
+			if c := s[i+1]; c >= '0' && c <= '9' {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// expandIndexed replaces ?N placeholders referring to N-th argument
+// with ? placeholders and returns the list of referenced arguments.
+//
+// A plain ? placeholder refers to an argument next to the one
+// with the largest index used so far, the way SQLite does it.
+//
+// If dedup is set, an argument is passed once and placeholders
+// referring to it once again are replaced with back references,
+// see backRef. Otherwise an argument is repeated for every placeholder.
+func expandIndexed(expr string, args []interface{}, dedup bool) (string, []interface{}, error) {
+	var (
+		buf   strings.Builder
+		out   = make([]interface{}, 0, len(args))
+		used  map[int]int
+		start int
+		last  int
+	)
+	if dedup {
+		used = make(map[int]int, len(args))
+	}
+	buf.Grow(len(expr))
+	for i := 0; i < len(expr); i++ {
+		switch expr[i] {
+		case '\\':
+			i++
+		case '?':
+			end := i + 1
+			for end < len(expr) && expr[end] >= '0' && expr[end] <= '9' {
+				end++
+			}
+			n := last + 1
+			if end > i+1 {
+				n, _ = strconv.Atoi(expr[i+1 : end])
+			}
+			if n < 1 || n > len(args) {
+				return expr, args, fmt.Errorf("sqlf: %s refers to argument %d of %d", expr[i:end], n, len(args))
+			}
+			buf.WriteString(expr[start : i+1])
+			start = end
+			if argNo, ok := used[n]; ok {
+				// Refer to an argument passed already
+				buf.WriteByte(0)
+				buf.WriteString(strconv.Itoa(len(out) - argNo))
+				buf.WriteByte(0)
+			} else {
+				if dedup {
+					used[n] = len(out)
+				}
+				out = append(out, args[n-1])
+			}
+			if n > last {
+				last = n
+			}
+			i = end - 1
+		}
+	}
+	buf.WriteString(expr[start:])
+	return buf.String(), out, nil
+}
+
+// backRef starts a reference to an argument an SQL fragment uses
+// more than once. It is followed by a distance from the next argument
+// to a referenced one and a zero byte. PostgreSQL and SQLite statements
+// render back references as $N and ?N placeholders.
+const backRef = "?\x00"
+
+// parseBackRef returns a distance to an argument a back reference
+// s starts with refers to and the length of the back reference.
+// The length is 0 if s doesn't start with a back reference.
+func parseBackRef(s string) (dist, n int) {
+	if !strings.HasPrefix(s, backRef) {
+		return 0, 0
+	}
+	end := strings.IndexByte(s[len(backRef):], 0)
+	if end < 0 {
+		return 0, 0
+	}
+	dist, _ = strconv.Atoi(s[len(backRef) : len(backRef)+end])
+	return dist, len(backRef) + end + 1
+}
+
+// jsonValue encodes a value to JSON when passed to a database driver.
+type jsonValue struct {
+	v interface{}
+}
+
+func (j jsonValue) Value() (driver.Value, error) {
+	b, err := json.Marshal(j.v)
+	if err != nil {
+		return nil, err
+	}
+	return string(b), nil
+}
+
+// jsonbPath converts a dot-separated path to a PostgreSQL text array literal.
+func jsonbPath(path string) string {
+	var buf strings.Builder
+	buf.WriteByte('{')
+	for i, key := range strings.Split(path, ".") {
+		if i > 0 {
+			buf.WriteByte(',')
+		}
+		buf.WriteByte('"')
+		for j := 0; j < len(key); j++ {
+			if key[j] == '"' || key[j] == '\\' {
+				buf.WriteByte('\\')
+			}
+			buf.WriteByte(key[j])
+		}
+		buf.WriteByte('"')
+	}
+	buf.WriteByte('}')
+	return buf.String()
+}
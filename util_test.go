@@ -19,3 +19,64 @@ func TestInsertAt(t *testing.T) {
 	a = insertAt([]interface{}{1, 2}, []interface{}{3}, 1)
 	require.Equal(t, a, []interface{}{1, 3, 2})
 }
+
+func TestSliceArgs(t *testing.T) {
+	require.Equal(t, []interface{}{1, 2}, sliceArgs([]int{1, 2}))
+	require.Equal(t, []interface{}{"a"}, sliceArgs([1]string{"a"}))
+	require.Equal(t, []interface{}{42}, sliceArgs(42))
+}
+
+func TestSplitTuples(t *testing.T) {
+	require.Equal(t, []int{0}, splitTuples(""))
+	require.Equal(t, []int{1}, splitTuples("a"))
+	require.Equal(t, []int{3}, splitTuples("a, COALESCE(b, 0), 'x, y'"))
+	require.Equal(t, []int{2, 2, 1}, splitTuples("?, ? ), ( ?, f(?, ?) ), ( ?"))
+}
+
+func TestSplitList(t *testing.T) {
+	require.Nil(t, splitList(" "))
+	require.Equal(t, []string{"a"}, splitList(" a "))
+	require.Equal(t, []string{"a", "COALESCE(b, ?)", "'x, y'", "(SELECT 1, 2)"},
+		splitList("a, COALESCE(b, ?), 'x, y', (SELECT 1, 2)"))
+}
+
+func TestExpandIndexed(t *testing.T) {
+	require.False(t, hasIndexedPlaceholders("a = ? AND b = ?"))
+	require.False(t, hasIndexedPlaceholders(`a \?1`))
+	require.True(t, hasIndexedPlaceholders("a = ?1"))
+
+	expr, args, err := expandIndexed("a <= ?1 AND b >= ?1", []interface{}{5}, false)
+	require.NoError(t, err)
+	require.Equal(t, "a <= ? AND b >= ?", expr)
+	require.Equal(t, []interface{}{5, 5}, args)
+
+	expr, args, err = expandIndexed("a <= ?1 AND b >= ?1", []interface{}{5}, true)
+	require.NoError(t, err)
+	require.Equal(t, "a <= ? AND b >= ?\x001\x00", expr)
+	require.Equal(t, []interface{}{5}, args)
+	require.Equal(t, 1, countPlaceholders(expr))
+
+	dist, n := parseBackRef(expr[len("a <= ? AND b >= "):])
+	require.Equal(t, 1, dist)
+	require.Equal(t, 4, n)
+
+	expr, args, err = expandIndexed("a = ?2 AND b = ?1 AND c = ?", []interface{}{1, 2, 3}, true)
+	require.NoError(t, err)
+	require.Equal(t, "a = ? AND b = ? AND c = ?", expr)
+	require.Equal(t, []interface{}{2, 1, 3}, args)
+
+	_, _, err = expandIndexed(`a \? ?1 AND b = ?7`, []interface{}{1}, false)
+	require.Error(t, err)
+}
+
+func TestJSONB(t *testing.T) {
+	require.Equal(t, `{"address","city"}`, jsonbPath("address.city"))
+	require.Equal(t, `{"a\"b","c\\d"}`, jsonbPath(`a"b.c\d`))
+
+	v, err := jsonValue{map[string]int{"a": 1}}.Value()
+	require.NoError(t, err)
+	require.Equal(t, `{"a":1}`, v)
+
+	_, err = jsonValue{func() {}}.Value()
+	require.Error(t, err)
+}